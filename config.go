@@ -670,6 +670,7 @@ type ConfigHeartbeat struct {
 	SourceId string `yaml:"source-id,omitempty"`
 	Role     string `yaml:"role,omitempty"`
 	Table    string `yaml:"table,omitempty"`
+	Disable  *bool  `yaml:"disable,omitempty"`
 }
 
 const (
@@ -706,6 +707,14 @@ func (c *ConfigHeartbeat) ApplyDefaults(b Config) {
 	if c.Freq != "" && c.Table == "" {
 		c.Table = DEFAULT_HEARTBEAT_TABLE
 	}
+	c.Disable = setBool(c.Disable, b.Heartbeat.Disable)
+}
+
+// Enabled returns true if freq is set and the heartbeat is not disabled.
+// Disable is for monitors that are not MySQL (like ProxySQL) when the
+// heartbeat is configured for all monitors.
+func (c ConfigHeartbeat) Enabled() bool {
+	return c.Freq != "" && !True(c.Disable)
 }
 
 func (c *ConfigHeartbeat) InterpolateEnvVars() {
@@ -867,6 +876,7 @@ type ConfigPlanChange struct {
 	Standby  ConfigStatePlan `yaml:"standby,omitempty"`
 	ReadOnly ConfigStatePlan `yaml:"read-only,omitempty"`
 	Active   ConfigStatePlan `yaml:"active,omitempty"`
+	Disable  *bool           `yaml:"disable,omitempty"`
 }
 
 type ConfigStatePlan struct {
@@ -902,6 +912,8 @@ func (c *ConfigPlanChange) ApplyDefaults(b Config) {
 	if c.Active.Plan == "" {
 		c.Active.Plan = b.Plans.Change.Active.Plan
 	}
+
+	c.Disable = setBool(c.Disable, b.Plans.Change.Disable)
 }
 
 func (c *ConfigPlanChange) InterpolateEnvVars() {
@@ -925,7 +937,13 @@ func (c *ConfigPlanChange) InterpolateMonitor(m *ConfigMonitor) {
 	c.Active.Plan = m.interpolateMon(c.Active.Plan)
 }
 
+// Enabled returns true if a state plan is set and plan change is not disabled.
+// Disable is for monitors that are not MySQL (like ProxySQL) where the state
+// (@@read_only) is not meaningful.
 func (c ConfigPlanChange) Enabled() bool {
+	if True(c.Disable) {
+		return false
+	}
 	return c.Offline.Plan != "" ||
 		c.Standby.Plan != "" ||
 		c.ReadOnly.Plan != "" ||
@@ -72,3 +72,34 @@ func TestApplyDefaultConfig(t *testing.T) {
 		t.Errorf("api.bind=%s, expected :1234", my.API.Bind)
 	}
 }
+
+func TestMonitorDisableHeartbeatAndPlanChange(t *testing.T) {
+	// Heartbeat and plan change configured for all monitors
+	b := blip.DefaultConfig()
+	b.Heartbeat.Freq = "1s"
+	b.Plans.Change.ReadOnly.Plan = "ro"
+
+	mysql := blip.ConfigMonitor{MonitorId: "mysql"}
+	mysql.ApplyDefaults(b)
+	if !mysql.Heartbeat.Enabled() {
+		t.Error("mysql: heartbeat not enabled, expected it to be enabled")
+	}
+	if !mysql.Plans.Change.Enabled() {
+		t.Error("mysql: plan change not enabled, expected it to be enabled")
+	}
+
+	// But disabled for a monitor that's not MySQL, like ProxySQL
+	disable := true
+	proxysql := blip.ConfigMonitor{
+		MonitorId: "proxysql",
+		Heartbeat: blip.ConfigHeartbeat{Disable: &disable},
+		Plans:     blip.ConfigPlans{Change: blip.ConfigPlanChange{Disable: &disable}},
+	}
+	proxysql.ApplyDefaults(b)
+	if proxysql.Heartbeat.Enabled() {
+		t.Error("proxysql: heartbeat enabled, expected it to be disabled")
+	}
+	if proxysql.Plans.Change.Enabled() {
+		t.Error("proxysql: plan change enabled, expected it to be disabled")
+	}
+}
@@ -51,6 +51,9 @@ const (
 	STATE_CHANGE_BEGIN       = "state-change-begin"
 	STATE_CHANGE_END         = "state-change-end"
 	REPL_SOURCE_CHANGE       = "repl-soruce-change"
+	SYSVAR_CHANGE            = "sysvar-change"
+	PFS_LOST                 = "pfs-lost"
+	BUFFER_POOL_SCAN_SKIPPED = "buffer-pool-scan-skipped"
 )
 
 const (
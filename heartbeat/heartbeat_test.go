@@ -3,6 +3,7 @@
 package heartbeat_test
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 	"time"
@@ -300,3 +301,95 @@ func TestReader(t *testing.T) {
 		t.Errorf("lag = %d ms, expected between 50 and 100 ms", lag2)
 	}
 }
+
+func TestPtHeartbeatReader(t *testing.T) {
+	_, db, err := test.Connection(test.DefaultMySQLVersion)
+	if err != nil {
+		if test.Build {
+			t.Skip(test.DefaultMySQLVersion + " not running")
+		} else {
+			t.Fatal(err)
+		}
+	}
+	defer db.Close()
+
+	// Create the pt-heartbeat table (pt-heartbeat --create-table) in the test db
+	if err := setupHeartbeatTable(db); err != nil {
+		t.Fatal(err)
+	}
+	ptTable := blip_writer_db + ".pt_heartbeat"
+	q := "CREATE TABLE " + ptTable + ` (
+  ts                    varchar(26) NOT NULL,
+  server_id             int unsigned NOT NULL PRIMARY KEY,
+  file                  varchar(255) DEFAULT NULL,
+  position              bigint unsigned DEFAULT NULL,
+  relay_master_log_file varchar(255) DEFAULT NULL,
+  exec_master_log_pos   bigint unsigned DEFAULT NULL
+)`
+	if _, err := db.Exec(q); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate pt-heartbeat --update on another server (server_id != @@server_id)
+	q = "INSERT INTO " + ptTable + " (ts, server_id, file, position) VALUES (DATE_FORMAT(NOW(6), '" + heartbeat.PT_TS_FORMAT + "'), 9999, 'binlog.000001', 4)"
+	if _, err := db.Exec(q); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same as TestReader: wrap the real LagWaiter to intercept the lag
+	hbChan := make(chan int64, 1)
+	realWaiter := heartbeat.SlowFastWaiter{NetworkLatency: 10 * time.Millisecond}
+	mockWaiter := mock.LagWaiter{
+		WaitFunc: func(now, then time.Time, f int, srcId string) (int64, time.Duration) {
+			if f != 200 {
+				t.Errorf("freq = %d, expected 200 (Interval)", f)
+			}
+			lag, wait := realWaiter.Wait(now, then, f, srcId)
+			hbChan <- lag
+			return lag, wait
+		},
+	}
+	hr := heartbeat.NewPtHeartbeatReader(heartbeat.PtHeartbeatReaderArgs{
+		MonitorId: "r1",
+		DB:        db,
+		Table:     ptTable,
+		Interval:  200 * time.Millisecond,
+		Waiter:    mockWaiter,
+	})
+	hr.Start()
+	defer hr.Stop()
+
+	timeout := time.After(5 * time.Second)
+
+	// First read: no lag yet
+	var lag int64
+	select {
+	case lag = <-hbChan:
+	case <-timeout:
+		t.Fatal("timeout waiting for LagWaiter")
+	}
+	if lag != 0 {
+		t.Errorf("lag = %d ms, expected 0 at start", lag)
+	}
+
+	// Second read: next heartbeat (in 200 ms) not written, so lagging
+	select {
+	case lag = <-hbChan:
+	case <-timeout:
+		t.Fatal("timeout waiting for LagWaiter")
+	}
+	if lag < 10 || lag > 50 {
+		t.Errorf("lag = %d ms, expected between 10 and 50 ms", lag)
+	}
+
+	got, err := hr.Lag(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Replica {
+		t.Error("Replica = false, expected true")
+	}
+	if got.SourceId != "9999" {
+		t.Errorf("SourceId = %s, expected 9999", got.SourceId)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2024 Block, Inc.
+
+package heartbeat
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/event"
+	"github.com/cashapp/blip/status"
+)
+
+const (
+	// DEFAULT_PT_HEARTBEAT_TABLE is the pt-heartbeat default (--database percona
+	// --table heartbeat).
+	DEFAULT_PT_HEARTBEAT_TABLE = "percona.heartbeat"
+
+	// DEFAULT_PT_HEARTBEAT_INTERVAL is the pt-heartbeat default (--interval 1.0).
+	DEFAULT_PT_HEARTBEAT_INTERVAL = time.Second
+
+	// PT_TS_FORMAT is the MySQL STR_TO_DATE format of pt-heartbeat ts values
+	// like "2024-01-02T15:04:05.123456".
+	PT_TS_FORMAT = "%Y-%m-%dT%H:%i:%s.%f"
+)
+
+// PtHeartbeatReader reads heartbeats from pt-heartbeat --update. The table is
+// the pt-heartbeat table (--create-table):
+//
+//	CREATE TABLE heartbeat (
+//	  ts                    varchar(26) NOT NULL,
+//	  server_id             int unsigned NOT NULL PRIMARY KEY,
+//	  file                  varchar(255) DEFAULT NULL,
+//	  position              bigint unsigned DEFAULT NULL,
+//	  relay_master_log_file varchar(255) DEFAULT NULL,
+//	  exec_master_log_pos   bigint unsigned DEFAULT NULL
+//	);
+//
+// pt-heartbeat does not write its interval (freq) to the table, so it must be
+// given by PtHeartbeatReaderArgs.Interval. Lag is computed the same way as
+// BlipReader: by the LagWaiter.
+type PtHeartbeatReader struct {
+	monitorId string
+	db        *sql.DB
+	table     string
+	srcId     string
+	replCheck string
+	freq      int // milliseconds
+	// --
+	waiter LagWaiter
+	*sync.Mutex
+	lag      int64
+	last     time.Time
+	stopChan chan struct{}
+	doneChan chan struct{}
+	isRepl   bool
+	event    event.MonitorReceiver
+	query    string
+	params   []interface{}
+}
+
+type PtHeartbeatReaderArgs struct {
+	MonitorId string
+	DB        *sql.DB
+	Table     string        // default DEFAULT_PT_HEARTBEAT_TABLE
+	SourceId  string        // server_id (pt-heartbeat --master-server-id)
+	Interval  time.Duration // default DEFAULT_PT_HEARTBEAT_INTERVAL
+	UTC       bool          // pt-heartbeat --utc
+	ReplCheck string
+	Waiter    LagWaiter
+}
+
+var _ Reader = &PtHeartbeatReader{}
+
+func NewPtHeartbeatReader(args PtHeartbeatReaderArgs) *PtHeartbeatReader {
+	if args.Table == "" {
+		args.Table = DEFAULT_PT_HEARTBEAT_TABLE
+	}
+	if args.Interval <= 0 {
+		args.Interval = DEFAULT_PT_HEARTBEAT_INTERVAL
+	}
+	r := &PtHeartbeatReader{
+		monitorId: args.MonitorId,
+		db:        args.DB,
+		table:     args.Table,
+		srcId:     args.SourceId,
+		replCheck: args.ReplCheck,
+		freq:      int(args.Interval.Milliseconds()),
+		// --
+		waiter:   args.Waiter,
+		Mutex:    &sync.Mutex{},
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+		lag:      -1, // no heartbeat
+		isRepl:   true,
+		event:    event.MonitorReceiver{MonitorId: args.MonitorId},
+	}
+
+	// Create heartbeat read query. pt-heartbeat writes ts in its local time zone
+	// unless --utc, in which case now must be UTC, too.
+	now := "NOW(6)"
+	if args.UTC {
+		now = "UTC_TIMESTAMP(6)"
+	}
+	cols := []string{
+		now,
+		"STR_TO_DATE(ts, '" + PT_TS_FORMAT + "')",
+		"server_id",
+		"COALESCE(file, '')",
+		"COALESCE(position, 0)",
+		"1",
+	}
+	var where string
+	if r.srcId != "" {
+		blip.Debug("%s: heartbeat from server_id %s", r.monitorId, r.srcId)
+		where = "WHERE server_id=?"
+		r.params = []interface{}{r.srcId}
+	} else {
+		// Like pt-heartbeat --check without --master-server-id, except the
+		// latest heartbeat not from this server
+		blip.Debug("%s: heartbeat from latest (max ts)", r.monitorId)
+		where = "WHERE server_id != @@server_id ORDER BY ts DESC LIMIT 1"
+	}
+	if r.replCheck != "" {
+		cols[5] = "@@" + r.replCheck
+	}
+	r.query = fmt.Sprintf("SELECT %s FROM %s %s", strings.Join(cols, ", "), r.table, where)
+
+	return r
+}
+
+func (r *PtHeartbeatReader) Start() error {
+	go r.run()
+	return nil
+}
+
+func (r *PtHeartbeatReader) run() {
+	defer close(r.doneChan)
+	blip.Debug("%s: pt-heartbeat reader: %s", r.monitorId, r.query)
+
+	var (
+		now    time.Time     // now according to MySQL
+		last   sql.NullTime  // last heartbeat
+		lag    int64         // lag since last
+		srcId  string        // server_id, might change if not set
+		file   string        // source binlog file
+		pos    uint64        // source binlog position
+		isRepl int           // @@repl-check
+		wait   time.Duration // wait time until next check
+		err    error
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		default:
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), ReadTimeout)
+		err = r.db.QueryRowContext(ctx, r.query, r.params...).Scan(&now, &last, &srcId, &file, &pos, &isRepl)
+		cancel()
+		if err == nil && !last.Valid {
+			err = fmt.Errorf("invalid ts in %s for server_id %s", r.table, srcId)
+		}
+		if err != nil {
+			blip.Debug("%s: %v", r.monitorId, err)
+			switch {
+			case err == sql.ErrNoRows:
+				r.Lock()
+				r.lag = -1 // no heartbeat
+				r.Unlock()
+				status.Monitor(r.monitorId, "error:"+status.HEARTBEAT_READER, "no pt-heartbeat for %s (retry in %s)", r.srcId, NoHeartbeatWait)
+				time.Sleep(NoHeartbeatWait)
+			default:
+				status.Monitor(r.monitorId, "error:"+status.HEARTBEAT_READER, "error: %s (retry in %s)", err.Error(), ReadErrorWait)
+				time.Sleep(ReadErrorWait)
+			}
+			continue
+		}
+		status.RemoveComponent(r.monitorId, "error:"+status.HEARTBEAT_READER)
+
+		if isRepl == 0 {
+			r.Lock()
+			r.isRepl = false
+			r.Unlock()
+			msg := fmt.Sprintf("not a replica: %s=%d (retry in %s)", r.replCheck, isRepl, ReplCheckWait)
+			blip.Debug("%s: %s", r.monitorId, msg)
+			status.Monitor(r.monitorId, status.HEARTBEAT_READER, "%s", msg)
+			time.Sleep(ReplCheckWait)
+			continue
+		}
+
+		// Repl source channge?
+		if r.srcId != srcId {
+			r.event.Sendf(event.REPL_SOURCE_CHANGE, "%s to %s", r.srcId, srcId)
+			r.srcId = srcId
+		}
+
+		lag, wait = r.waiter.Wait(now, last.Time, r.freq, srcId)
+
+		r.Lock()
+		r.isRepl = true
+		r.lag = lag
+		r.last = last.Time
+		r.Unlock()
+
+		status.Monitor(r.monitorId, status.HEARTBEAT_READER, "%d ms lag from server_id %s (%s:%d), next in %s", lag, srcId, file, pos, wait)
+		time.Sleep(wait)
+	}
+}
+
+func (r *PtHeartbeatReader) Stop() {
+	r.Lock()
+	select {
+	case <-r.stopChan:
+	case <-r.doneChan:
+	default:
+		close(r.stopChan)
+	}
+	r.Unlock()
+}
+
+func (r *PtHeartbeatReader) Lag(_ context.Context) (Lag, error) {
+	r.Lock()
+	defer r.Unlock()
+	if !r.isRepl {
+		return Lag{Replica: false, Milliseconds: -1}, nil
+	}
+	return Lag{Milliseconds: r.lag, LastTs: r.last, SourceId: r.srcId, Replica: true}, nil
+}
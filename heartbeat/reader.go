@@ -17,8 +17,8 @@ import (
 
 // Reader reads heartbeats from a writer. It runs in a separate goroutine and
 // reports replication lag for the repl.lag metric collector, where it's also
-// created in Prepare. Currently, there's only one implementation: BlipReader,
-// but an implementation for pt-table-heartbeat is an idea.
+// created in Prepare. There are two implementations: BlipReader for BlipWriter,
+// and PtHeartbeatReader for pt-heartbeat.
 type Reader interface {
 	Start() error
 	Stop()
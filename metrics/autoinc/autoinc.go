@@ -5,7 +5,9 @@ package autoinc
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/cashapp/blip"
 )
@@ -15,15 +17,24 @@ const (
 
 	OPT_EXCLUDE = "exclude"
 	OPT_INCLUDE = "include"
+	OPT_HISTORY = "history"
 )
 
+type autoincLevel struct {
+	query      string
+	params     []interface{}
+	usage      bool
+	exhaustion bool
+	window     time.Duration
+	history    map[string]*history // keyed on db.tbl.col
+}
+
 // AutoInc collects auto-increment utilization for the autoinc domain.
 // https://dev.mysql.com/doc/refman/8.0/en/sys-schema-auto-increment-columns.html
 type AutoInc struct {
 	db *sql.DB
 	// --
-	query  map[string]string
-	params map[string][]interface{}
+	atLevel map[string]*autoincLevel
 }
 
 // Verify collector implements blip.Collector interface.
@@ -32,9 +43,8 @@ var _ blip.Collector = &AutoInc{}
 // NewAutoIncrement makes a new AutoIncrement collector,
 func NewAutoInc(db *sql.DB) *AutoInc {
 	return &AutoInc{
-		db:     db,
-		query:  map[string]string{},
-		params: map[string][]interface{}{},
+		db:      db,
+		atLevel: map[string]*autoincLevel{},
 	}
 }
 
@@ -58,6 +68,11 @@ func (t *AutoInc) Help() blip.CollectorHelp {
 				Desc:    "Comma-separated list of database or table names to exclude (ignored if " + OPT_INCLUDE + " is set)",
 				Default: "mysql.*,information_schema.*,performance_schema.*,sys.*",
 			},
+			OPT_HISTORY: {
+				Name:    OPT_HISTORY,
+				Desc:    "How long to keep AUTO_INCREMENT values to calculate the consumption rate for seconds_until_exhaustion",
+				Default: "1h",
+			},
 		},
 		Groups: []blip.CollectorKeyValue{
 			{Key: "db", Value: "the database name for the corresponding auto increment value"},
@@ -65,12 +80,21 @@ func (t *AutoInc) Help() blip.CollectorHelp {
 			{Key: "col", Value: "the column name for the corresponding auto increment value"},
 			{Key: "data_type", Value: "the data type of the column"},
 		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "base_type", Value: "the data type of the column without signedness, like int"},
+			{Key: "unsigned", Value: "yes if the column is unsigned, else no"},
+		},
 		Metrics: []blip.CollectorMetric{
 			{
 				Name: "usage",
 				Type: blip.GAUGE,
 				Desc: "The percentage of the auto increment range used",
 			},
+			{
+				Name: "seconds_until_exhaustion",
+				Type: blip.GAUGE,
+				Desc: "Seconds until the auto increment range is used at the rate of consumption during option " + OPT_HISTORY,
+			},
 		},
 	}
 }
@@ -94,19 +118,50 @@ LEVEL:
 		if err != nil {
 			return nil, err
 		}
-		t.query[level.Name] = q
-		t.params[level.Name] = params
+		l := &autoincLevel{
+			query:   q,
+			params:  params,
+			history: map[string]*history{},
+		}
+
+		// Only usage if no metrics are specified, which was the only metric
+		// before seconds_until_exhaustion
+		if len(dom.Metrics) == 0 {
+			l.usage = true
+		}
+		for _, name := range dom.Metrics {
+			switch name {
+			case "usage":
+				l.usage = true
+			case "seconds_until_exhaustion":
+				l.exhaustion = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+		}
+
+		window, ok := dom.Options[OPT_HISTORY]
+		if !ok {
+			window = t.Help().Options[OPT_HISTORY].Default
+		}
+		l.window, err = time.ParseDuration(window)
+		if err != nil || l.window <= 0 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be a duration greater than zero, like 1h", OPT_HISTORY, window)
+		}
+
+		t.atLevel[level.Name] = l
 	}
 	return nil, nil
 }
 
 func (t *AutoInc) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
-	q, ok := t.query[levelName]
+	l, ok := t.atLevel[levelName]
 	if !ok {
 		return nil, nil
 	}
 
-	rows, err := t.db.QueryContext(ctx, q, t.params[levelName]...)
+	now := time.Now()
+	rows, err := t.db.QueryContext(ctx, l.query, l.params...)
 	if err != nil {
 		return nil, err
 	}
@@ -121,44 +176,90 @@ func (t *AutoInc) Collect(ctx context.Context, levelName string) ([]blip.MetricV
 		isUnsigned bool
 		autoincVal int64
 	)
+	seen := make(map[string]*history, len(l.history))
 
 	for rows.Next() {
 		if err = rows.Scan(&dbName, &tblName, &colName, &colType, &isUnsigned, &autoincVal); err != nil {
 			return nil, err
 		}
 
-		var maxSize uint64
-		colType = strings.ToLower(colType)
-		switch colType {
-		case "tinyint":
-			maxSize = 255
-		case "smallint":
-			maxSize = 65535
-		case "mediumint":
-			maxSize = 16777215
-		case "int":
-			maxSize = 4294967295
-		case "bigint":
-			maxSize = 18446744073709551615
-		default:
-			// unknown type, skip
-			continue
+		group, meta, maxSize, ok := columnLabels(dbName, tblName, colName, colType, isUnsigned)
+		if !ok {
+			continue // unknown type
 		}
 
-		if !isUnsigned {
-			maxSize = maxSize >> 1
-		} else {
-			colType = colType + " unsigned"
+		if l.usage {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "usage",
+				Type:  blip.GAUGE,
+				Group: group,
+				Meta:  meta,
+				Value: float64(autoincVal) / float64(maxSize),
+			})
 		}
 
-		m := blip.MetricValue{
-			Name:  "usage",
-			Type:  blip.GAUGE,
-			Group: map[string]string{"db": dbName, "tbl": tblName, "col": colName, "data_type": colType},
-			Value: float64(autoincVal) / float64(maxSize),
+		if l.exhaustion {
+			key := dbName + "." + tblName + "." + colName
+			h, ok := l.history[key]
+			if !ok {
+				h = &history{}
+			}
+			seen[key] = h
+			h.add(now, float64(autoincVal), l.window)
+			if secs, ok := h.secondsUntil(float64(maxSize)); ok {
+				metrics = append(metrics, blip.MetricValue{
+					Name:  "seconds_until_exhaustion",
+					Type:  blip.GAUGE,
+					Group: group,
+					Meta:  meta,
+					Value: secs,
+				})
+			}
 		}
-		metrics = append(metrics, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return metrics, err
+	// Keep only columns in this collection so history doesn't grow unbounded
+	// when tables are dropped
+	if l.exhaustion {
+		l.history = seen
+	}
+
+	return metrics, nil
+}
+
+// columnLabels returns the group and meta for an auto-increment column, and the
+// max value of its data type. It returns false if the data type is unknown.
+// Group data_type includes signedness, like "int unsigned"; meta base_type does
+// not, like "int". They must have different keys because sinks merge group and
+// meta into one set of tags or labels.
+func columnLabels(dbName, tblName, colName, colType string, isUnsigned bool) (map[string]string, map[string]string, uint64, bool) {
+	var maxSize uint64
+	colType = strings.ToLower(colType)
+	meta := map[string]string{"base_type": colType, "unsigned": "no"}
+	switch colType {
+	case "tinyint":
+		maxSize = 255
+	case "smallint":
+		maxSize = 65535
+	case "mediumint":
+		maxSize = 16777215
+	case "int":
+		maxSize = 4294967295
+	case "bigint":
+		maxSize = 18446744073709551615
+	default:
+		return nil, nil, 0, false
+	}
+
+	if !isUnsigned {
+		maxSize = maxSize >> 1
+	} else {
+		colType = colType + " unsigned"
+		meta["unsigned"] = "yes"
+	}
+	group := map[string]string{"db": dbName, "tbl": tblName, "col": colName, "data_type": colType}
+	return group, meta, maxSize, true
 }
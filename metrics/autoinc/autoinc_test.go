@@ -0,0 +1,54 @@
+// Copyright 2024 Block, Inc.
+
+package autoinc
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestColumnLabels(t *testing.T) {
+	group, meta, maxSize, ok := columnLabels("db1", "t1", "id", "INT", true)
+	if !ok {
+		t.Fatal("unknown data type, expected int")
+	}
+	expectGroup := map[string]string{"db": "db1", "tbl": "t1", "col": "id", "data_type": "int unsigned"}
+	if diff := deep.Equal(group, expectGroup); diff != nil {
+		t.Error(diff)
+	}
+	expectMeta := map[string]string{"base_type": "int", "unsigned": "yes"}
+	if diff := deep.Equal(meta, expectMeta); diff != nil {
+		t.Error(diff)
+	}
+	if maxSize != 4294967295 {
+		t.Errorf("got max %d, expected 4294967295", maxSize)
+	}
+
+	_, meta, maxSize, _ = columnLabels("db1", "t1", "id", "bigint", false)
+	if meta["unsigned"] != "no" {
+		t.Errorf("got unsigned=%s, expected no", meta["unsigned"])
+	}
+	if maxSize != 9223372036854775807 {
+		t.Errorf("got max %d, expected 9223372036854775807", maxSize)
+	}
+
+	if _, _, _, ok := columnLabels("db1", "t1", "id", "decimal", false); ok {
+		t.Error("got ok for decimal, expected unknown data type")
+	}
+}
+
+func TestColumnLabelsKeys(t *testing.T) {
+	// Sinks merge group and meta, so the same key would be reported twice or
+	// one value would overwrite the other
+	for _, colType := range []string{"tinyint", "smallint", "mediumint", "int", "bigint"} {
+		for _, unsigned := range []bool{true, false} {
+			group, meta, _, _ := columnLabels("db1", "t1", "id", colType, unsigned)
+			for k := range meta {
+				if _, ok := group[k]; ok {
+					t.Errorf("%s unsigned=%t: meta key %s is also a group key", colType, unsigned, k)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Block, Inc.
+
+package autoinc
+
+import (
+	"time"
+)
+
+type sample struct {
+	ts    time.Time
+	value float64
+}
+
+// history is the AUTO_INCREMENT values of one column over a time window,
+// oldest first, to calculate the consumption rate.
+type history struct {
+	samples []sample
+}
+
+// add adds a sample and drops samples older than the window. The oldest sample
+// at or before the start of the window is kept, so the rate is calculated over
+// the full window once there is enough history. If the value decreased (for
+// example, the table was truncated), the history is reset.
+func (h *history) add(ts time.Time, value float64, window time.Duration) {
+	if n := len(h.samples); n > 0 && value < h.samples[n-1].value {
+		h.samples = h.samples[:0]
+	}
+	h.samples = append(h.samples, sample{ts: ts, value: value})
+	start := ts.Add(-window)
+	for len(h.samples) > 2 && !h.samples[1].ts.After(start) {
+		h.samples = h.samples[1:]
+	}
+}
+
+// rate returns the consumption rate (values per second) and true, or false
+// if there are not enough samples.
+func (h *history) rate() (float64, bool) {
+	if len(h.samples) < 2 {
+		return 0, false
+	}
+	first, last := h.samples[0], h.samples[len(h.samples)-1]
+	secs := last.ts.Sub(first.ts).Seconds()
+	if secs <= 0 {
+		return 0, false
+	}
+	return (last.value - first.value) / secs, true
+}
+
+// secondsUntil returns the number of seconds until the last value reaches max
+// at the current rate, and true. It returns false if there is no rate or the
+// rate is zero, i.e. the column is not being consumed.
+func (h *history) secondsUntil(max float64) (float64, bool) {
+	r, ok := h.rate()
+	if !ok || r <= 0 {
+		return 0, false
+	}
+	left := max - h.samples[len(h.samples)-1].value
+	if left < 0 {
+		left = 0
+	}
+	return left / r, true
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Block, Inc.
+
+package autoinc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := 3 * time.Minute
+	h := &history{}
+
+	// One sample: no rate
+	h.add(t0, 1000, window)
+	if _, ok := h.secondsUntil(2000); ok {
+		t.Error("got seconds until exhaustion with 1 sample, expected none")
+	}
+
+	// 100 values per minute, 1000 left: 10 minutes
+	h.add(t0.Add(1*time.Minute), 1100, window)
+	got, ok := h.secondsUntil(2100)
+	if !ok {
+		t.Fatal("no seconds until exhaustion with 2 samples")
+	}
+	if got != 600 {
+		t.Errorf("got %f seconds, expected 600", got)
+	}
+
+	// Samples outside the window are dropped, except the oldest one at the
+	// start of the window
+	h.add(t0.Add(2*time.Minute), 1200, window)
+	h.add(t0.Add(3*time.Minute), 1300, window)
+	h.add(t0.Add(4*time.Minute), 1700, window)
+	if len(h.samples) != 4 {
+		t.Errorf("got %d samples, expected 4: %+v", len(h.samples), h.samples)
+	}
+	if !h.samples[0].ts.Equal(t0.Add(1 * time.Minute)) {
+		t.Errorf("oldest sample at %s, expected %s", h.samples[0].ts, t0.Add(1*time.Minute))
+	}
+	// (1700-1100) / 180s = 3.33/s, 1000 left = 300s
+	got, _ = h.secondsUntil(2700)
+	if got != 300 {
+		t.Errorf("got %f seconds, expected 300", got)
+	}
+
+	// No consumption: no prediction
+	h = &history{}
+	h.add(t0, 1000, window)
+	h.add(t0.Add(time.Minute), 1000, window)
+	if _, ok := h.secondsUntil(2000); ok {
+		t.Error("got seconds until exhaustion with zero rate, expected none")
+	}
+
+	// Value decreased (table truncated): history reset
+	h.add(t0.Add(2*time.Minute), 1, window)
+	if len(h.samples) != 1 {
+		t.Errorf("got %d samples after reset, expected 1", len(h.samples))
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright 2024 Block, Inc.
+
+package awsaurora
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	"github.com/cashapp/blip"
+	awsrds "github.com/cashapp/blip/metrics/aws.rds"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	DOMAIN = "aws.aurora"
+
+	OPT_DB_ID      = "db-id"
+	OPT_CLUSTER_ID = "cluster-id"
+)
+
+// Metrics from information_schema.replica_host_status, not CloudWatch.
+const (
+	METRIC_REPLICA_LAG         = "replica_lag"
+	METRIC_REPLICA_LAST_UPDATE = "replica_last_update"
+
+	// Aurora MySQL v2 is REPLICA_LAG_IN_MSEC, v3 is REPLICA_LAG_IN_MILLISECONDS.
+	// The query selects * to work with either.
+	replicaHostStatusQuery = "SELECT *, TIMESTAMPDIFF(MICROSECOND, LAST_UPDATE_TIMESTAMP, UTC_TIMESTAMP(6)) / 1000000 AS BLIP_LAST_UPDATE_AGE FROM information_schema.replica_host_status"
+)
+
+var (
+	auroraNamespace = aws.String("AWS/RDS")
+	auroraAverage   = aws.String("Average")
+	auroraDbId      = aws.String("DBInstanceIdentifier")
+	auroraClusterId = aws.String("DBClusterIdentifier")
+	aurora60s       = aws.Int32(60)
+)
+
+// CloudWatch metrics that are only reported per cluster (dimension DBClusterIdentifier).
+// All other CloudWatch metrics are reported per instance (dimension DBInstanceIdentifier).
+// https://docs.aws.amazon.com/AmazonRDS/latest/AuroraUserGuide/Aurora.AuroraMonitoring.Metrics.html
+var clusterMetrics = map[string]bool{
+	"AuroraGlobalDBDataTransferBytes":    true,
+	"AuroraGlobalDBProgressLag":          true,
+	"AuroraGlobalDBReplicatedWriteIO":    true,
+	"AuroraGlobalDBReplicationLag":       true,
+	"AuroraGlobalDBRPOLag":               true,
+	"AuroraVolumeBytesLeftTotal":         true,
+	"BacktrackChangeRecordsCreationRate": true,
+	"BacktrackChangeRecordsStored":       true,
+	"BackupRetentionPeriodStorageUsed":   true,
+	"SnapshotStorageUsed":                true,
+	"TotalBackupStorageBilled":           true,
+	"VolumeBytesUsed":                    true,
+	"VolumeReadIOPs":                     true,
+	"VolumeWriteIOPs":                    true,
+}
+
+type cwMetric struct {
+	name    string
+	cluster bool
+}
+
+type auroraLevel struct {
+	input    *cloudwatch.GetMetricDataInput // nil if no CloudWatch metrics
+	metrics  map[string]cwMetric            // keyed on MetricDataQuery.Id
+	latestTs map[string]time.Time           // keyed on MetricDataQuery.Id
+	lag      bool                           // collect METRIC_REPLICA_LAG
+	update   bool                           // collect METRIC_REPLICA_LAST_UPDATE
+
+	// Options are per level because each level has its own CloudWatch queries
+	dbId      string
+	clusterId string
+}
+
+// Aurora collects Amazon Aurora metrics from two sources: CloudWatch
+// (like aws.rds, but including cluster-level metrics) and, in the database,
+// information_schema.replica_host_status.
+type Aurora struct {
+	db     *sql.DB
+	client awsrds.CloudWatchClient
+	// --
+	monitorId string
+	atLevel   map[string]*auroraLevel // keyed on level
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &Aurora{}
+
+// NewAurora makes a new Aurora collector.
+func NewAurora(db *sql.DB, client awsrds.CloudWatchClient) *Aurora {
+	return &Aurora{
+		db:      db,
+		client:  client,
+		atLevel: map[string]*auroraLevel{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *Aurora) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *Aurora) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Amazon Aurora metrics like 'AuroraReplicaLag' and 'VolumeBytesUsed', and replica host status",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_DB_ID: {
+				Name:    OPT_DB_ID,
+				Desc:    "Database instance identifier for instance-level CloudWatch metrics",
+				Default: "%%{monitor.id}",
+			},
+			OPT_CLUSTER_ID: {
+				Name: OPT_CLUSTER_ID,
+				Desc: "Database cluster identifier for cluster-level CloudWatch metrics like VolumeBytesUsed (required to collect cluster-level metrics)",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "cluster", Value: "the cluster ID for cluster-level CloudWatch metrics"},
+			{Key: "server_id", Value: "the instance ID (SERVER_ID) for replica host status metrics"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "ts", Value: "the CloudWatch timestamp (milliseconds) of CloudWatch metrics"},
+			{Key: "session_id", Value: "the SESSION_ID for replica host status metrics (MASTER_SESSION_ID for the writer)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "AuroraReplicaLag",
+				Type: blip.GAUGE,
+				Desc: "Replica lag in milliseconds (CloudWatch, instance)",
+			},
+			{
+				Name: "AuroraBinlogReplicaLag",
+				Type: blip.GAUGE,
+				Desc: "Binary log replica lag in seconds (CloudWatch, instance)",
+			},
+			{
+				Name: "BufferCacheHitRatio",
+				Type: blip.GAUGE,
+				Desc: "Percentage of requests served by the buffer cache (CloudWatch, instance)",
+			},
+			{
+				Name: "VolumeBytesUsed",
+				Type: blip.GAUGE,
+				Desc: "Cluster volume size in bytes (CloudWatch, cluster)",
+			},
+			{
+				Name: METRIC_REPLICA_LAG,
+				Type: blip.GAUGE,
+				Desc: "Replica lag in milliseconds (replica_host_status)",
+			},
+			{
+				Name: METRIC_REPLICA_LAST_UPDATE,
+				Type: blip.GAUGE,
+				Desc: "Seconds since replica status was last updated (replica_host_status)",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *Aurora) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	c.monitorId = plan.MonitorId
+
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("at %s/%s/%s: no metrics specified; expected at least 1 metric (AuroraReplicaLag, %s, etc.)",
+				plan.Name, level.Name, DOMAIN, METRIC_REPLICA_LAG)
+		}
+
+		l := &auroraLevel{
+			metrics:   map[string]cwMetric{},
+			latestTs:  map[string]time.Time{},
+			dbId:      dom.Options[OPT_DB_ID],
+			clusterId: dom.Options[OPT_CLUSTER_ID],
+		}
+		if l.dbId == "" {
+			l.dbId = plan.MonitorId
+		}
+		queries := []types.MetricDataQuery{}
+		for _, metric := range dom.Metrics {
+			switch metric {
+			case METRIC_REPLICA_LAG:
+				l.lag = true
+				continue
+			case METRIC_REPLICA_LAST_UPDATE:
+				l.update = true
+				continue
+			}
+
+			m := cwMetric{name: metric, cluster: clusterMetrics[metric]}
+			id := strings.ToLower(metric) // must match /^[a-z][a-zA-Z0-9_]*$/
+			dim := types.Dimension{Name: auroraDbId, Value: aws.String(l.dbId)}
+			if m.cluster {
+				if l.clusterId == "" {
+					return nil, fmt.Errorf("at %s/%s/%s: cluster-level metric %s requires option %s",
+						plan.Name, level.Name, DOMAIN, metric, OPT_CLUSTER_ID)
+				}
+				dim = types.Dimension{Name: auroraClusterId, Value: aws.String(l.clusterId)}
+			}
+			l.metrics[id] = m
+			l.latestTs[id] = time.Time{}
+
+			queries = append(queries, types.MetricDataQuery{
+				Id: aws.String(id),
+				MetricStat: &types.MetricStat{
+					Stat:   auroraAverage,
+					Period: aurora60s, // max resolution for CloudWatch Metrics
+					Metric: &types.Metric{
+						MetricName: aws.String(metric),
+						Namespace:  auroraNamespace,
+						Dimensions: []types.Dimension{dim},
+					},
+				},
+			})
+		}
+
+		if len(queries) > 0 {
+			l.input = &cloudwatch.GetMetricDataInput{
+				//StartTime: &begin, // set in Collect
+				//EndTime:   &now,   // set in Collect
+				ScanBy:            types.ScanByTimestampAscending,
+				MetricDataQueries: queries,
+			}
+		}
+
+		c.atLevel[level.Name] = l
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *Aurora) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	l, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	metrics := []blip.MetricValue{}
+
+	if l.input != nil {
+		m, err := c.collectCloudWatch(ctx, l)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if l.lag || l.update {
+		m, err := c.collectReplicaHostStatus(ctx, l)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	return metrics, nil
+}
+
+func (c *Aurora) collectCloudWatch(ctx context.Context, l *auroraLevel) ([]blip.MetricValue, error) {
+	// Request the last 2 minutes because CloudWatch metrics trail by 1-2 minutes.
+	// See the code comments in aws.rds.Collect for details.
+	now := time.Now()
+	begin := now.Add(-2 * time.Minute).Round(time.Minute)
+	l.input.StartTime = &begin
+	l.input.EndTime = &now
+
+	output, err := c.client.GetMetricData(ctx, l.input)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := []blip.MetricValue{}
+	for i := range output.MetricDataResults {
+		r := output.MetricDataResults[i]
+		if r.Id == nil {
+			continue
+		}
+		id := *r.Id
+		cw, ok := l.metrics[id]
+		if !ok {
+			continue
+		}
+		for j := range r.Timestamps {
+			// If AWS ts is not after lastest ts, then it's an old or duplicate value
+			// that we've already reported; skip it
+			if !r.Timestamps[j].After(l.latestTs[id]) {
+				blip.Debug("%s: drop: %s %s = %f\n", c.monitorId, r.Timestamps[j], cw.name, r.Values[j])
+				continue
+			}
+			blip.Debug("%s: keep: %s %s = %f\n", c.monitorId, r.Timestamps[j], cw.name, r.Values[j])
+			l.latestTs[id] = r.Timestamps[j]
+			m := blip.MetricValue{
+				Name:  cw.name,
+				Type:  blip.GAUGE,
+				Value: r.Values[j],
+				Meta: map[string]string{
+					"ts": fmt.Sprintf("%d", r.Timestamps[j].UnixMilli()), // must be milliseconds
+				},
+			}
+			if cw.cluster {
+				m.Group = map[string]string{"cluster": l.clusterId}
+			}
+			metrics = append(metrics, m)
+		}
+	}
+
+	return metrics, nil
+}
+
+func (c *Aurora) collectReplicaHostStatus(ctx context.Context, l *auroraLevel) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, replicaHostStatusQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]sql.NullString, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+
+	metrics := []blip.MetricValue{}
+	for rows.Next() {
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(cols))
+		for i, col := range cols {
+			if vals[i].Valid {
+				row[strings.ToUpper(col)] = vals[i].String
+			}
+		}
+
+		group := map[string]string{"server_id": row["SERVER_ID"]}
+		meta := map[string]string{"session_id": row["SESSION_ID"]}
+
+		if l.lag {
+			lag, ok := row["REPLICA_LAG_IN_MILLISECONDS"]
+			if !ok {
+				lag = row["REPLICA_LAG_IN_MSEC"]
+			}
+			if v, ok := sqlutil.Float64(lag); ok {
+				metrics = append(metrics, blip.MetricValue{
+					Name:  METRIC_REPLICA_LAG,
+					Type:  blip.GAUGE,
+					Value: v,
+					Group: group,
+					Meta:  meta,
+				})
+			}
+		}
+
+		if l.update {
+			if v, ok := sqlutil.Float64(row["BLIP_LAST_UPDATE_AGE"]); ok {
+				metrics = append(metrics, blip.MetricValue{
+					Name:  METRIC_REPLICA_LAST_UPDATE,
+					Type:  blip.GAUGE,
+					Value: v,
+					Group: group,
+					Meta:  meta,
+				})
+			}
+		}
+	}
+
+	return metrics, rows.Err()
+}
@@ -0,0 +1,197 @@
+// Copyright 2024 Block, Inc.
+
+package awsaurora_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+	awsaurora "github.com/cashapp/blip/metrics/aws.aurora"
+	"github.com/cashapp/blip/test/mock"
+)
+
+func TestCollectCloudWatch(t *testing.T) {
+	ts := time.Now().Add(-1 * time.Minute).Truncate(time.Minute)
+
+	var gotInput *cloudwatch.GetMetricDataInput
+	client := mock.CloudWatchClient{
+		GetMetricDataFunc: func(ctx context.Context, in *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error) {
+			gotInput = in
+			return &cloudwatch.GetMetricDataOutput{
+				MetricDataResults: []types.MetricDataResult{
+					{
+						Id:         aws.String("aurorareplicalag"),
+						Label:      aws.String("AuroraReplicaLag"),
+						Timestamps: []time.Time{ts},
+						Values:     []float64{12.5},
+					},
+					{
+						Id:         aws.String("volumebytesused"),
+						Label:      aws.String("VolumeBytesUsed"),
+						Timestamps: []time.Time{ts},
+						Values:     []float64{1024},
+					},
+				},
+			}, nil
+		},
+	}
+
+	plan := blip.Plan{
+		Name:      "test",
+		MonitorId: "db1",
+		Levels: map[string]blip.Level{
+			"kpi": {
+				Name: "kpi",
+				Collect: map[string]blip.Domain{
+					awsaurora.DOMAIN: {
+						Name: awsaurora.DOMAIN,
+						Options: map[string]string{
+							awsaurora.OPT_CLUSTER_ID: "cluster1",
+						},
+						Metrics: []string{"AuroraReplicaLag", "VolumeBytesUsed"},
+					},
+				},
+			},
+		},
+	}
+
+	c := awsaurora.NewAurora(nil, client) // nil db: no replica_host_status metrics
+	if _, err := c.Prepare(context.Background(), plan); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.Collect(context.Background(), "kpi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Instance metric filtered by db-id (default: monitor ID), cluster metric by cluster-id
+	if gotInput == nil || len(gotInput.MetricDataQueries) != 2 {
+		t.Fatalf("got input %+v, expected 2 metric data queries", gotInput)
+	}
+	dims := []string{}
+	for _, q := range gotInput.MetricDataQueries {
+		d := q.MetricStat.Metric.Dimensions[0]
+		dims = append(dims, *d.Name+"="+*d.Value)
+	}
+	if diff := deep.Equal(dims, []string{"DBInstanceIdentifier=db1", "DBClusterIdentifier=cluster1"}); diff != nil {
+		t.Error(diff)
+	}
+
+	tsMeta := map[string]string{"ts": fmt.Sprintf("%d", ts.UnixMilli())}
+	expect := []blip.MetricValue{
+		{
+			Name:  "AuroraReplicaLag",
+			Type:  blip.GAUGE,
+			Value: 12.5,
+			Meta:  tsMeta,
+		},
+		{
+			Name:  "VolumeBytesUsed",
+			Type:  blip.GAUGE,
+			Value: 1024,
+			Group: map[string]string{"cluster": "cluster1"},
+			Meta:  tsMeta,
+		},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Same timestamps again are duplicates, so they're dropped
+	got, err = c.Collect(context.Background(), "kpi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %d metrics on second collect, expected 0: %+v", len(got), got)
+	}
+}
+
+func TestPrepareClusterIdRequired(t *testing.T) {
+	plan := blip.Plan{
+		Name:      "test",
+		MonitorId: "db1",
+		Levels: map[string]blip.Level{
+			"kpi": {
+				Name: "kpi",
+				Collect: map[string]blip.Domain{
+					awsaurora.DOMAIN: {
+						Name:    awsaurora.DOMAIN,
+						Metrics: []string{"VolumeBytesUsed"},
+					},
+				},
+			},
+		},
+	}
+	c := awsaurora.NewAurora(nil, mock.CloudWatchClient{})
+	if _, err := c.Prepare(context.Background(), plan); err == nil {
+		t.Error("no error for cluster-level metric without cluster-id, expected an error")
+	}
+}
+
+func TestPrepareOptionsPerLevel(t *testing.T) {
+	// Level kpi has cluster-id and a cluster metric; level slow has neither but a
+	// different db-id. Options from one level must not affect the other.
+	var gotInput *cloudwatch.GetMetricDataInput
+	client := mock.CloudWatchClient{
+		GetMetricDataFunc: func(ctx context.Context, in *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error) {
+			gotInput = in
+			return &cloudwatch.GetMetricDataOutput{}, nil
+		},
+	}
+	plan := blip.Plan{
+		Name:      "test",
+		MonitorId: "db1",
+		Levels: map[string]blip.Level{
+			"kpi": {
+				Name: "kpi",
+				Collect: map[string]blip.Domain{
+					awsaurora.DOMAIN: {
+						Name:    awsaurora.DOMAIN,
+						Options: map[string]string{awsaurora.OPT_CLUSTER_ID: "cluster1"},
+						Metrics: []string{"VolumeBytesUsed"},
+					},
+				},
+			},
+			"slow": {
+				Name: "slow",
+				Collect: map[string]blip.Domain{
+					awsaurora.DOMAIN: {
+						Name:    awsaurora.DOMAIN,
+						Options: map[string]string{awsaurora.OPT_DB_ID: "db2"},
+						Metrics: []string{"AuroraReplicaLag"},
+					},
+				},
+			},
+		},
+	}
+	c := awsaurora.NewAurora(nil, client)
+	if _, err := c.Prepare(context.Background(), plan); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range []struct {
+		name string
+		dim  string
+	}{
+		{"kpi", "DBClusterIdentifier=cluster1"},
+		{"slow", "DBInstanceIdentifier=db2"},
+	} {
+		if _, err := c.Collect(context.Background(), level.name); err != nil {
+			t.Fatal(err)
+		}
+		d := gotInput.MetricDataQueries[0].MetricStat.Metric.Dimensions[0]
+		if got := *d.Name + "=" + *d.Value; got != level.dim {
+			t.Errorf("level %s: got dimension %s, expected %s", level.name, got, level.dim)
+		}
+	}
+}
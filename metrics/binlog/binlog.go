@@ -0,0 +1,460 @@
+// Copyright 2024 Block, Inc.
+
+package binlog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/errors"
+	sizebinlog "github.com/cashapp/blip/metrics/size.binlog"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	DOMAIN = "binlog"
+
+	FILES_QUERY  = "SHOW BINARY LOGS"
+	CACHE_QUERY  = "SHOW GLOBAL STATUS LIKE 'Binlog\\_cache\\_%'"
+	EXPIRE_QUERY = "SELECT @@global.binlog_expire_logs_seconds"
+
+	// MySQL 5.7 does not have binlog_expire_logs_seconds
+	EXPIRE_DAYS_QUERY = "SELECT @@global.expire_logs_days * 86400"
+)
+
+// Metrics by source: SHOW BINARY LOGS or Binlog_cache_% status variables.
+var (
+	filesMetrics = map[string]bool{
+		"files":               true,
+		"bytes":               true,
+		"current_file_bytes":  true,
+		"bytes_written":       true,
+		"seconds_until_purge": true,
+	}
+	cacheMetrics = map[string]bool{
+		"cache_use":         true,
+		"cache_disk_use":    true,
+		"cache_spill_ratio": true,
+	}
+)
+
+// File is one binary log from SHOW BINARY LOGS.
+type File struct {
+	Name string
+	Size float64
+}
+
+type binlogLevel struct {
+	metrics map[string]bool
+	files   bool // collect from SHOW BINARY LOGS
+	cache   bool // collect Binlog_cache_% status variables
+	// State for calculating deltas between collections:
+	lastFile  File               // current binlog at last collection
+	lastCache map[string]float64 // Binlog_cache_% at last collection
+}
+
+// Binlog collects metrics for the binlog domain.
+type Binlog struct {
+	db *sql.DB
+	// --
+	atLevel   map[string]*binlogLevel
+	errPolicy map[string]*errors.Policy
+	stop      bool
+	// When each binlog was created, shared by all levels: see Created
+	*sync.Mutex
+	created map[string]time.Time
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &Binlog{}
+
+// NewBinlog makes a new Binlog collector.
+func NewBinlog(db *sql.DB) *Binlog {
+	return &Binlog{
+		db:        db,
+		atLevel:   map[string]*binlogLevel{},
+		errPolicy: map[string]*errors.Policy{},
+		Mutex:     &sync.Mutex{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *Binlog) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *Binlog) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Binary log files, growth, cache spills, and purge time",
+		Errors:      sizebinlog.ErrorsHelp(),
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "files",
+				Type: blip.GAUGE,
+				Desc: "Number of binary logs",
+			},
+			{
+				Name: "bytes",
+				Type: blip.GAUGE,
+				Desc: "Total size of all binary logs in bytes",
+			},
+			{
+				Name: "current_file_bytes",
+				Type: blip.GAUGE,
+				Desc: "Size of the current binary log in bytes",
+			},
+			{
+				Name: "bytes_written",
+				Type: blip.DELTA_COUNTER,
+				Desc: "Bytes written to binary logs since the last collection",
+			},
+			{
+				Name: "seconds_until_purge",
+				Type: blip.GAUGE,
+				Desc: "Seconds until the oldest binary log expires (binlog_expire_logs_seconds); not reported after Blip starts until binary logs that existed before have been purged, which can take up to binlog_expire_logs_seconds",
+			},
+			{
+				Name: "cache_use",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Transactions that used the binary log cache (Binlog_cache_use)",
+			},
+			{
+				Name: "cache_disk_use",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Transactions that used the binary log cache but exceeded binlog_cache_size and used a temporary file (Binlog_cache_disk_use)",
+			},
+			{
+				Name: "cache_spill_ratio",
+				Type: blip.GAUGE,
+				Desc: "Ratio of cache_disk_use to cache_use since the last collection",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *Binlog) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected at this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+		l := &binlogLevel{
+			metrics: make(map[string]bool, len(dom.Metrics)),
+		}
+		for _, name := range dom.Metrics {
+			switch {
+			case filesMetrics[name]:
+				l.files = true
+			case cacheMetrics[name]:
+				l.cache = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+			l.metrics[name] = true
+		}
+
+		// Apply custom error policies, if any
+		c.errPolicy[sizebinlog.ERR_NO_ACCESS] = errors.NewPolicy(dom.Errors[sizebinlog.ERR_NO_ACCESS])
+		c.errPolicy[sizebinlog.ERR_NO_BINLOGS] = errors.NewPolicy(dom.Errors[sizebinlog.ERR_NO_BINLOGS])
+		blip.Debug("error policy: %s=%s %s=%s", sizebinlog.ERR_NO_ACCESS, c.errPolicy[sizebinlog.ERR_NO_ACCESS],
+			sizebinlog.ERR_NO_BINLOGS, c.errPolicy[sizebinlog.ERR_NO_BINLOGS])
+
+		c.atLevel[level.Name] = l
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *Binlog) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	l, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	// Files and cache metrics are collected independently: an error from
+	// one does not drop metrics from the other
+	var (
+		metrics []blip.MetricValue
+		err     error
+	)
+
+	if l.files && !c.stop {
+		var m []blip.MetricValue
+		m, err = c.collectFiles(ctx, l)
+		metrics = append(metrics, m...) // partial metrics on error
+		if err != nil {
+			m, err = c.collectError(err, l)
+			metrics = append(metrics, m...)
+		}
+	}
+
+	if l.cache {
+		m, cacheErr := c.collectCache(ctx, l)
+		metrics = append(metrics, m...)
+		if cacheErr != nil {
+			if err != nil {
+				err = fmt.Errorf("%s; %s", err, cacheErr)
+			} else {
+				err = cacheErr
+			}
+		}
+	}
+
+	return metrics, err
+}
+
+func (c *Binlog) collectFiles(ctx context.Context, l *binlogLevel) ([]blip.MetricValue, error) {
+	now := time.Now()
+	files, err := c.files(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	var metrics []blip.MetricValue
+	cur := files[len(files)-1]
+
+	if l.metrics["files"] {
+		metrics = append(metrics, blip.MetricValue{Name: "files", Type: blip.GAUGE, Value: float64(len(files))})
+	}
+
+	if l.metrics["bytes"] {
+		var total float64
+		for _, f := range files {
+			total += f.Size
+		}
+		metrics = append(metrics, blip.MetricValue{Name: "bytes", Type: blip.GAUGE, Value: total})
+	}
+
+	if l.metrics["current_file_bytes"] {
+		metrics = append(metrics, blip.MetricValue{Name: "current_file_bytes", Type: blip.GAUGE, Value: cur.Size})
+	}
+
+	if l.metrics["bytes_written"] {
+		if n, ok := BytesWritten(l.lastFile, files); ok {
+			metrics = append(metrics, blip.MetricValue{Name: "bytes_written", Type: blip.DELTA_COUNTER, Value: n})
+		}
+		l.lastFile = cur
+	}
+
+	if l.metrics["seconds_until_purge"] {
+		c.Lock()
+		c.created = Created(c.created, files, now)
+		created := c.created
+		c.Unlock()
+
+		expire, err := c.expireSeconds(ctx)
+		if err != nil {
+			return metrics, err // return files metrics already collected
+		}
+		if s, ok := SecondsUntilPurge(files, created, expire, now); ok {
+			metrics = append(metrics, blip.MetricValue{Name: "seconds_until_purge", Type: blip.GAUGE, Value: s})
+		}
+	}
+
+	return metrics, nil
+}
+
+func (c *Binlog) files(ctx context.Context) ([]File, error) {
+	rows, err := c.db.QueryContext(ctx, FILES_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// As of MySQL 8.0.14, SHOW BINARY LOGS has 3 cols instead of 2
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		files []File
+		name  string
+		size  string
+		enc   string
+	)
+	dest := []interface{}{&name, &size}
+	if len(cols) == 3 {
+		dest = append(dest, &enc)
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		n, ok := sqlutil.Float64(size)
+		if !ok {
+			continue
+		}
+		files = append(files, File{Name: name, Size: n})
+	}
+	return files, rows.Err()
+}
+
+func (c *Binlog) expireSeconds(ctx context.Context) (float64, error) {
+	var expire float64
+	err := c.db.QueryRowContext(ctx, EXPIRE_QUERY).Scan(&expire)
+	if err != nil {
+		if err2 := c.db.QueryRowContext(ctx, EXPIRE_DAYS_QUERY).Scan(&expire); err2 != nil {
+			return 0, err // first error is more relevant
+		}
+	}
+	return expire, nil
+}
+
+func (c *Binlog) collectCache(ctx context.Context, l *binlogLevel) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, CACHE_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		name string
+		val  string
+	)
+	cur := map[string]float64{}
+	for rows.Next() {
+		if err = rows.Scan(&name, &val); err != nil {
+			return nil, err
+		}
+		if n, ok := sqlutil.Float64(val); ok {
+			cur[name] = n
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var metrics []blip.MetricValue
+	if l.metrics["cache_use"] {
+		metrics = append(metrics, blip.MetricValue{Name: "cache_use", Type: blip.CUMULATIVE_COUNTER, Value: cur["Binlog_cache_use"]})
+	}
+	if l.metrics["cache_disk_use"] {
+		metrics = append(metrics, blip.MetricValue{Name: "cache_disk_use", Type: blip.CUMULATIVE_COUNTER, Value: cur["Binlog_cache_disk_use"]})
+	}
+	if l.metrics["cache_spill_ratio"] && l.lastCache != nil {
+		use := cur["Binlog_cache_use"] - l.lastCache["Binlog_cache_use"]
+		disk := cur["Binlog_cache_disk_use"] - l.lastCache["Binlog_cache_disk_use"]
+		if use > 0 && disk >= 0 { // no transactions or counter reset
+			metrics = append(metrics, blip.MetricValue{Name: "cache_spill_ratio", Type: blip.GAUGE, Value: disk / use})
+		}
+	}
+	l.lastCache = cur
+
+	return metrics, nil
+}
+
+func (c *Binlog) collectError(err error, l *binlogLevel) ([]blip.MetricValue, error) {
+	ep := sizebinlog.ErrorPolicy(err, c.errPolicy)
+	if ep == nil {
+		return nil, err
+	}
+
+	// Stop trying to collect if error policy retry="stop". This affects
+	// future calls to Collect; don't return yet because we need to check
+	// the metric policy: drop or zero. If zero, we must report zero values.
+	if ep.Retry == errors.POLICY_RETRY_NO {
+		c.stop = true
+	}
+
+	// Report
+	var reportedErr error
+	if ep.ReportError() {
+		reportedErr = err
+	} else {
+		blip.Debug("error policy=ignore: %s", err)
+	}
+
+	// Zero only gauges; deltas and time until purge are unknown, not zero
+	var metrics []blip.MetricValue
+	if ep.Metric == errors.POLICY_METRIC_ZERO {
+		for _, name := range []string{"files", "bytes", "current_file_bytes"} {
+			if l.metrics[name] {
+				metrics = append(metrics, blip.MetricValue{Name: name, Type: blip.GAUGE, Value: 0})
+			}
+		}
+	}
+
+	return metrics, reportedErr
+}
+
+// BytesWritten returns the number of bytes written to binlogs since last,
+// the current binlog at the last collection, and true. It returns false if
+// there is no last binlog (first collection) or last is no longer listed
+// in files (purged, or binlogs were reset).
+func BytesWritten(last File, files []File) (float64, bool) {
+	if last.Name == "" {
+		return 0, false
+	}
+	for i := range files {
+		if files[i].Name != last.Name {
+			continue
+		}
+		// Bytes written to the last binlog after the last collection, plus all
+		// binlogs after it if binlogs rotated
+		n := files[i].Size - last.Size
+		for _, f := range files[i+1:] {
+			n += f.Size
+		}
+		if n < 0 {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+// Created returns when each binlog in files was created, as first seen by Blip.
+// If created is nil (first collection), all binlogs have a zero time because
+// they were created before Blip started. Else, binlogs not in created were
+// created now. Binlogs not in files (purged) are removed.
+//
+// MySQL does not report when a binlog was created, so this is the best estimate.
+// The estimate is only as precise as the collection interval.
+func Created(created map[string]time.Time, files []File, now time.Time) map[string]time.Time {
+	first := created == nil
+	m := make(map[string]time.Time, len(files))
+	for _, f := range files {
+		if t, ok := created[f.Name]; ok {
+			m[f.Name] = t
+		} else if first {
+			m[f.Name] = time.Time{}
+		} else {
+			m[f.Name] = now
+		}
+	}
+	return m
+}
+
+// SecondsUntilPurge returns the number of seconds until the oldest binlog expires,
+// and true. It returns false if binlogs don't expire (expire = 0), there's only the
+// current binlog (which isn't purged), or when the oldest binlog was last modified
+// is unknown. A binlog is last modified when the next binlog is created, so the
+// oldest binlog expires expire seconds after the second oldest was created. The
+// value is negative if the oldest binlog has expired but not been purged yet
+// because MySQL purges binlogs only when it rotates the current binlog.
+func SecondsUntilPurge(files []File, created map[string]time.Time, expire float64, now time.Time) (float64, bool) {
+	if expire <= 0 || len(files) < 2 {
+		return 0, false
+	}
+	t := created[files[1].Name]
+	if t.IsZero() {
+		return 0, false
+	}
+	return t.Add(time.Duration(expire) * time.Second).Sub(now).Seconds(), true
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 Block, Inc.
+
+package binlog_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip/metrics/binlog"
+)
+
+func TestBytesWritten(t *testing.T) {
+	files := []binlog.File{
+		{Name: "binlog.000001", Size: 1000},
+		{Name: "binlog.000002", Size: 500},
+	}
+
+	// First collection
+	if _, ok := binlog.BytesWritten(binlog.File{}, files); ok {
+		t.Error("got bytes written on first collection, expected none")
+	}
+
+	// Written to current binlog
+	got, ok := binlog.BytesWritten(binlog.File{Name: "binlog.000002", Size: 200}, files)
+	if !ok {
+		t.Fatal("no bytes written, expected 300")
+	}
+	if got != 300 {
+		t.Errorf("got %f bytes written, expected 300", got)
+	}
+
+	// Rotated: rest of last binlog plus all of new binlogs
+	files = append(files, binlog.File{Name: "binlog.000003", Size: 50})
+	got, _ = binlog.BytesWritten(binlog.File{Name: "binlog.000001", Size: 900}, files)
+	if got != 650 {
+		t.Errorf("got %f bytes written, expected 650", got)
+	}
+
+	// Last binlog purged
+	if _, ok := binlog.BytesWritten(binlog.File{Name: "binlog.000000", Size: 900}, files); ok {
+		t.Error("got bytes written after last binlog purged, expected none")
+	}
+}
+
+func TestSecondsUntilPurge(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []binlog.File{
+		{Name: "binlog.000001", Size: 1000},
+		{Name: "binlog.000002", Size: 500},
+	}
+
+	// First collection: binlogs were created before Blip started, so when
+	// they were created is unknown
+	created := binlog.Created(nil, files, t0)
+	expect := map[string]time.Time{"binlog.000001": {}, "binlog.000002": {}}
+	if diff := deep.Equal(created, expect); diff != nil {
+		t.Error(diff)
+	}
+	if _, ok := binlog.SecondsUntilPurge(files, created, 3600, t0); ok {
+		t.Error("got seconds until purge when created time unknown, expected none")
+	}
+
+	// Binlog 3 created, binlog 1 purged: binlog 2 was last modified when binlog 3
+	// was created (t1), so it expires at t1 + 1h
+	files = []binlog.File{
+		{Name: "binlog.000002", Size: 1000},
+		{Name: "binlog.000003", Size: 500},
+	}
+	t1 := t0.Add(10 * time.Minute)
+	created = binlog.Created(created, files, t1)
+	expect = map[string]time.Time{"binlog.000002": {}, "binlog.000003": t1}
+	if diff := deep.Equal(created, expect); diff != nil {
+		t.Error(diff)
+	}
+	got, ok := binlog.SecondsUntilPurge(files, created, 3600, t1)
+	if !ok {
+		t.Fatal("no seconds until purge, expected 3600")
+	}
+	if got != 3600 {
+		t.Errorf("got %f seconds until purge, expected 3600", got)
+	}
+
+	// Binlog 4 created 20 minutes later: binlog 2 is still oldest, so it
+	// expires in 40 minutes
+	files = append(files, binlog.File{Name: "binlog.000004", Size: 10})
+	t2 := t1.Add(20 * time.Minute)
+	created = binlog.Created(created, files, t2)
+	got, ok = binlog.SecondsUntilPurge(files, created, 3600, t2)
+	if !ok {
+		t.Fatal("no seconds until purge, expected 2400")
+	}
+	if got != 2400 {
+		t.Errorf("got %f seconds until purge, expected 2400", got)
+	}
+
+	// Binlogs don't expire
+	if _, ok := binlog.SecondsUntilPurge(files, created, 0, t2); ok {
+		t.Error("got seconds until purge when expire = 0, expected none")
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2024 Block, Inc.
+
+package error
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	SUB_DOMAIN_REPL = "repl"
+
+	OPT_MESSAGE_LENGTH = "message-length"
+
+	GRP_REPL_CHANNEL = "channel"
+	GRP_REPL_THREAD  = "thread"
+	GRP_REPL_WORKER  = "worker"
+
+	// LAST_ERROR_TIMESTAMP is zero (0000-00-00) if no error, for which
+	// TIMESTAMPDIFF returns NULL, so age is 0 if no error.
+	errorAge = "COALESCE(TIMESTAMPDIFF(MICROSECOND, LAST_ERROR_TIMESTAMP, NOW(6)) / 1000000, 0)"
+
+	ERRORS_QUERY_REPL = "SELECT 'connection', CHANNEL_NAME, '', LAST_ERROR_NUMBER, LAST_ERROR_MESSAGE, " + errorAge + " FROM performance_schema.replication_connection_status" +
+		" UNION ALL SELECT 'coordinator', CHANNEL_NAME, '', LAST_ERROR_NUMBER, LAST_ERROR_MESSAGE, " + errorAge + " FROM performance_schema.replication_applier_status_by_coordinator" +
+		" UNION ALL SELECT 'worker', CHANNEL_NAME, CAST(WORKER_ID AS CHAR), LAST_ERROR_NUMBER, LAST_ERROR_MESSAGE, " + errorAge + " FROM performance_schema.replication_applier_status_by_worker"
+
+	RETRIES_QUERY_REPL = "SELECT CHANNEL_NAME, COUNT_TRANSACTIONS_RETRIES FROM performance_schema.replication_applier_status"
+
+	WORKER_RETRIES_QUERY_REPL = "SELECT CHANNEL_NAME, CAST(WORKER_ID AS CHAR), APPLYING_TRANSACTION_RETRIES_COUNT, LAST_APPLIED_TRANSACTION_RETRIES_COUNT FROM performance_schema.replication_applier_status_by_worker"
+)
+
+type replErrorOptions struct {
+	errors        bool // last_error or error_age
+	retries       bool
+	workerRetries bool // applying_retries or last_applied_retries
+	metrics       map[string]bool
+	messageLength int
+}
+
+// ErrorRepl collects replication errors for the error.repl domain.
+// Unlike the other error domains, the sources are not error summary tables
+// but the replication status tables:
+// https://dev.mysql.com/doc/refman/8.4/en/performance-schema-replication-tables.html
+type ErrorRepl struct {
+	db *sql.DB
+	// --
+	options map[string]*replErrorOptions
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &ErrorRepl{}
+
+// NewErrorRepl makes a new ErrorRepl collector.
+func NewErrorRepl(db *sql.DB) *ErrorRepl {
+	return &ErrorRepl{
+		db:      db,
+		options: make(map[string]*replErrorOptions),
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (t *ErrorRepl) Domain() string {
+	return DOMAIN + "." + SUB_DOMAIN_REPL
+}
+
+// Help returns the output for blip --print-domains.
+func (t *ErrorRepl) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN + "." + SUB_DOMAIN_REPL,
+		Description: "Replication connection and applier errors",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_MESSAGE_LENGTH: {
+				Name:    OPT_MESSAGE_LENGTH,
+				Desc:    "Maximum length of error_message meta, or 0 to disable",
+				Default: "200",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: GRP_REPL_CHANNEL, Value: "the replication channel name (empty string for the default channel)"},
+			{Key: GRP_REPL_THREAD, Value: "the replication thread: connection, coordinator, or worker (empty string for retries)"},
+			{Key: GRP_REPL_WORKER, Value: "the worker ID if thread is worker, else empty string"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "error_message", Value: "the last error message, truncated to option " + OPT_MESSAGE_LENGTH + " (only if there is an error)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "last_error",
+				Type: blip.GAUGE,
+				Desc: "Last error number (LAST_ERROR_NUMBER), or 0 if no error",
+			},
+			{
+				Name: "error_age",
+				Type: blip.GAUGE,
+				Desc: "Seconds since last error (LAST_ERROR_TIMESTAMP), reported only if there is an error",
+			},
+			{
+				Name: "retries",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Number of transaction retries by the applier (COUNT_TRANSACTIONS_RETRIES); MySQL does not count connection retries",
+			},
+			{
+				Name: "applying_retries",
+				Type: blip.GAUGE,
+				Desc: "Number of retries of the transaction the worker is applying (APPLYING_TRANSACTION_RETRIES_COUNT)",
+			},
+			{
+				Name: "last_applied_retries",
+				Type: blip.GAUGE,
+				Desc: "Number of retries of the last transaction the worker applied (LAST_APPLIED_TRANSACTION_RETRIES_COUNT)",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (t *ErrorRepl) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[t.Domain()]
+		if !ok {
+			continue
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+
+		o := &replErrorOptions{
+			metrics:       map[string]bool{},
+			messageLength: 200,
+		}
+		for _, name := range dom.Metrics {
+			switch name {
+			case "last_error", "error_age":
+				o.errors = true
+			case "retries":
+				o.retries = true
+			case "applying_retries", "last_applied_retries":
+				o.workerRetries = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+			o.metrics[name] = true
+		}
+
+		if s, ok := dom.Options[OPT_MESSAGE_LENGTH]; ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than or equal to zero", OPT_MESSAGE_LENGTH, s)
+			}
+			o.messageLength = n
+		}
+
+		t.options[level.Name] = o
+	}
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (t *ErrorRepl) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	o, ok := t.options[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	var metrics []blip.MetricValue
+
+	if o.errors {
+		m, err := t.collectErrors(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if o.retries {
+		m, err := t.collectRetries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if o.workerRetries {
+		m, err := t.collectWorkerRetries(ctx, o)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	return metrics, nil
+}
+
+func (t *ErrorRepl) collectErrors(ctx context.Context, o *replErrorOptions) ([]blip.MetricValue, error) {
+	rows, err := t.db.QueryContext(ctx, ERRORS_QUERY_REPL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics  []blip.MetricValue
+		thread   string
+		channel  string
+		worker   string
+		errorNum float64
+		message  string
+		age      float64
+	)
+	for rows.Next() {
+		if err = rows.Scan(&thread, &channel, &worker, &errorNum, &message, &age); err != nil {
+			return nil, err
+		}
+
+		metrics = append(metrics, errorMetrics(o, thread, channel, worker, errorNum, message, age)...)
+	}
+
+	return metrics, rows.Err()
+}
+
+func (t *ErrorRepl) collectRetries(ctx context.Context) ([]blip.MetricValue, error) {
+	rows, err := t.db.QueryContext(ctx, RETRIES_QUERY_REPL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics []blip.MetricValue
+		channel string
+		retries float64
+	)
+	for rows.Next() {
+		if err = rows.Scan(&channel, &retries); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "retries",
+			Type:  blip.CUMULATIVE_COUNTER,
+			Value: retries,
+			Group: map[string]string{GRP_REPL_CHANNEL: channel, GRP_REPL_THREAD: "", GRP_REPL_WORKER: ""},
+		})
+	}
+
+	return metrics, rows.Err()
+}
+
+func (t *ErrorRepl) collectWorkerRetries(ctx context.Context, o *replErrorOptions) ([]blip.MetricValue, error) {
+	rows, err := t.db.QueryContext(ctx, WORKER_RETRIES_QUERY_REPL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics  []blip.MetricValue
+		channel  string
+		worker   string
+		applying float64
+		applied  float64
+	)
+	for rows.Next() {
+		if err = rows.Scan(&channel, &worker, &applying, &applied); err != nil {
+			return nil, err
+		}
+		group := map[string]string{GRP_REPL_CHANNEL: channel, GRP_REPL_THREAD: "worker", GRP_REPL_WORKER: worker}
+		if o.metrics["applying_retries"] {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "applying_retries",
+				Type:  blip.GAUGE,
+				Value: applying,
+				Group: group,
+			})
+		}
+		if o.metrics["last_applied_retries"] {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "last_applied_retries",
+				Type:  blip.GAUGE,
+				Value: applied,
+				Group: group,
+			})
+		}
+	}
+
+	return metrics, rows.Err()
+}
+
+// errorMetrics returns the last_error and error_age metrics for one replication
+// thread (one row of ERRORS_QUERY_REPL). error_age and meta error_message are
+// set only if there is an error (errorNum != 0).
+func errorMetrics(o *replErrorOptions, thread, channel, worker string, errorNum float64, message string, age float64) []blip.MetricValue {
+	var metrics []blip.MetricValue
+
+	group := map[string]string{GRP_REPL_CHANNEL: channel, GRP_REPL_THREAD: thread, GRP_REPL_WORKER: worker}
+	var meta map[string]string
+	if errorNum != 0 && o.messageLength > 0 {
+		meta = map[string]string{"error_message": sqlutil.Truncate(message, o.messageLength)}
+	}
+
+	if o.metrics["last_error"] {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "last_error",
+			Type:  blip.GAUGE,
+			Value: errorNum,
+			Group: group,
+			Meta:  meta,
+		})
+	}
+	if o.metrics["error_age"] && errorNum != 0 {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "error_age",
+			Type:  blip.GAUGE,
+			Value: age,
+			Group: group,
+			Meta:  meta,
+		})
+	}
+
+	return metrics
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Block, Inc.
+
+package error
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+)
+
+func TestErrorMetrics(t *testing.T) {
+	o := &replErrorOptions{
+		metrics:       map[string]bool{"last_error": true, "error_age": true},
+		messageLength: 10,
+	}
+
+	// Error: both metrics with truncated message
+	got := errorMetrics(o, "worker", "ch1", "2", 1062, "Could not execute Write_rows event", 5.5)
+	group := map[string]string{GRP_REPL_CHANNEL: "ch1", GRP_REPL_THREAD: "worker", GRP_REPL_WORKER: "2"}
+	meta := map[string]string{"error_message": "Could not "}
+	expect := []blip.MetricValue{
+		{Name: "last_error", Type: blip.GAUGE, Value: 1062, Group: group, Meta: meta},
+		{Name: "error_age", Type: blip.GAUGE, Value: 5.5, Group: group, Meta: meta},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// No error: last_error = 0, no error_age or meta
+	got = errorMetrics(o, "connection", "", "", 0, "", 0)
+	expect = []blip.MetricValue{
+		{Name: "last_error", Type: blip.GAUGE, Value: 0, Group: map[string]string{GRP_REPL_CHANNEL: "", GRP_REPL_THREAD: "connection", GRP_REPL_WORKER: ""}},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// message-length=0 disables meta
+	o.messageLength = 0
+	got = errorMetrics(o, "coordinator", "", "", 13117, "error", 1)
+	for _, m := range got {
+		if m.Meta != nil {
+			t.Errorf("%s: got meta %v, expected none when message-length=0", m.Name, m.Meta)
+		}
+	}
+}
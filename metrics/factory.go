@@ -7,24 +7,43 @@ import (
 	"sort"
 	"sync"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+
 	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/metrics/autoinc"
+	awsaurora "github.com/cashapp/blip/metrics/aws.aurora"
 	awsrds "github.com/cashapp/blip/metrics/aws.rds"
+	"github.com/cashapp/blip/metrics/binlog"
 	errordomain "github.com/cashapp/blip/metrics/error"
+	"github.com/cashapp/blip/metrics/file"
 	"github.com/cashapp/blip/metrics/innodb"
 	innodbbufferpool "github.com/cashapp/blip/metrics/innodb.buffer-pool"
+	"github.com/cashapp/blip/metrics/lock"
+	"github.com/cashapp/blip/metrics/memory"
+	osdomain "github.com/cashapp/blip/metrics/os"
 	"github.com/cashapp/blip/metrics/percona"
+	"github.com/cashapp/blip/metrics/pfs"
+	"github.com/cashapp/blip/metrics/processlist"
+	"github.com/cashapp/blip/metrics/proxysql"
 	queryresponsetime "github.com/cashapp/blip/metrics/query.response-time"
 	"github.com/cashapp/blip/metrics/repl"
 	repllag "github.com/cashapp/blip/metrics/repl.lag"
+	replsource "github.com/cashapp/blip/metrics/repl.source"
+	"github.com/cashapp/blip/metrics/rocksdb"
 	sizebinlog "github.com/cashapp/blip/metrics/size.binlog"
 	sizedatabase "github.com/cashapp/blip/metrics/size.database"
+	sizefile "github.com/cashapp/blip/metrics/size.file"
+	sizeindex "github.com/cashapp/blip/metrics/size.index"
 	sizetable "github.com/cashapp/blip/metrics/size.table"
+	sqldomain "github.com/cashapp/blip/metrics/sql"
+	statusdomain "github.com/cashapp/blip/metrics/status"
 	statusglobal "github.com/cashapp/blip/metrics/status.global"
 	"github.com/cashapp/blip/metrics/stmt.current"
+	stmtdigest "github.com/cashapp/blip/metrics/stmt.digest"
 	"github.com/cashapp/blip/metrics/tls"
 	"github.com/cashapp/blip/metrics/trx"
 	varglobal "github.com/cashapp/blip/metrics/var.global"
+	"github.com/cashapp/blip/metrics/wait"
 	waitiotable "github.com/cashapp/blip/metrics/wait.io.table"
 )
 
@@ -250,86 +269,176 @@ func (f *factory) Make(domain string, args blip.CollectorFactoryArgs) (blip.Coll
 	switch domain {
 	case "autoinc":
 		return autoinc.NewAutoInc(args.DB), nil
+	case "aws.aurora":
+		if args.Validate {
+			return awsaurora.NewAurora(args.DB, nil), nil
+		}
+		awsConfig, err := f.awsConfig(args)
+		if err != nil {
+			return nil, err
+		}
+		return awsaurora.NewAurora(args.DB, awsrds.NewCloudWatchClient(awsConfig)), nil
 	case "aws.rds":
 		if args.Validate {
 			return awsrds.NewRDS(nil), nil
 		}
-		region := args.Config.AWS.Region
-		if region == "" && !blip.True(args.Config.AWS.DisableAutoRegion) {
-			region = "auto"
-		}
-		awsConfig, err := f.AWSConfig.Make(blip.AWS{Region: region}, args.Config.Hostname)
+		awsConfig, err := f.awsConfig(args)
 		if err != nil {
 			return nil, err
 		}
 		return awsrds.NewRDS(awsrds.NewCloudWatchClient(awsConfig)), nil
+	case "binlog":
+		return binlog.NewBinlog(args.DB), nil
 	case "error.account":
 		return errordomain.NewErrorAccount(args.DB), nil
 	case "error.global":
 		return errordomain.NewErrorGlobal(args.DB), nil
 	case "error.host":
 		return errordomain.NewErrorHost(args.DB), nil
+	case "error.repl":
+		return errordomain.NewErrorRepl(args.DB), nil
 	case "error.thread":
 		return errordomain.NewErrorThread(args.DB), nil
 	case "error.user":
 		return errordomain.NewErrorUser(args.DB), nil
+	case "file":
+		return file.NewFile(args.DB), nil
 	case "innodb":
 		return innodb.NewInnoDB(args.DB), nil
 	case "innodb.buffer-pool":
 		return innodbbufferpool.NewBufferPoolStats(args.DB), nil
+	case "lock":
+		return lock.NewLock(args.DB), nil
+	case "memory":
+		return memory.NewMemory(args.DB), nil
+	case "os":
+		return osdomain.NewOS(osdomain.OSArgs{DB: args.DB, Local: osdomain.Local(args.Config)}), nil
 	case "percona.response-time":
 		return percona.NewQRT(args.DB), nil
+	case "percona.userstat.client":
+		return percona.NewUserstatClient(args.DB), nil
+	case "percona.userstat.index":
+		return percona.NewUserstatIndex(args.DB), nil
+	case "percona.userstat.table":
+		return percona.NewUserstatTable(args.DB), nil
+	case "percona.userstat.user":
+		return percona.NewUserstatUser(args.DB), nil
+	case "pfs":
+		return pfs.NewPFS(args.DB), nil
+	case "processlist":
+		return processlist.NewProcesslist(args.DB), nil
+	case "proxysql.connection-pool":
+		return proxysql.NewConnectionPool(args.DB), nil
+	case "proxysql.global":
+		return proxysql.NewGlobal(args.DB), nil
+	case "proxysql.query-digest":
+		return proxysql.NewQueryDigest(args.DB), nil
 	case "query.response-time":
 		return queryresponsetime.NewResponseTime(args.DB), nil
 	case "repl":
 		return repl.NewRepl(args.DB), nil
 	case "repl.lag":
 		return repllag.NewLag(args.DB), nil
+	case "repl.source":
+		return replsource.NewSource(args.DB), nil
+	case "rocksdb":
+		return rocksdb.NewRocksDB(args.DB), nil
 	case "size.binlog":
 		return sizebinlog.NewBinlog(args.DB), nil
 	case "size.database":
 		return sizedatabase.NewDatabase(args.DB), nil
+	case "size.file":
+		return sizefile.NewFile(args.DB), nil
+	case "size.index":
+		return sizeindex.NewIndex(args.DB), nil
 	case "size.table":
 		return sizetable.NewTable(args.DB), nil
+	case "sql":
+		return sqldomain.NewSQL(args.DB), nil
+	case "status.account":
+		return statusdomain.NewStatusAccount(args.DB), nil
 	case "status.global":
 		return statusglobal.NewGlobal(args.DB), nil
+	case "status.host":
+		return statusdomain.NewStatusHost(args.DB), nil
+	case "status.user":
+		return statusdomain.NewStatusUser(args.DB), nil
 	case "stmt.current":
 		return stmt.NewCurrent(args.DB), nil
+	case "stmt.digest":
+		return stmtdigest.NewDigest(args.DB), nil
 	case "tls":
 		return tls.NewTLS(args.DB), nil
 	case "trx":
 		return trx.NewTrx(args.DB), nil
 	case "var.global":
 		return varglobal.NewGlobal(args.DB), nil
+	case "wait":
+		return wait.NewWait(args.DB), nil
 	case "wait.io.table":
 		return waitiotable.NewTable(args.DB), nil
 	}
 	return nil, fmt.Errorf("invalid domain: %s", domain)
 }
 
+// awsConfig makes the AWS config for collectors that query the AWS API.
+func (f *factory) awsConfig(args blip.CollectorFactoryArgs) (aws.Config, error) {
+	region := args.Config.AWS.Region
+	if region == "" && !blip.True(args.Config.AWS.DisableAutoRegion) {
+		region = "auto"
+	}
+	return f.AWSConfig.Make(blip.AWS{Region: region}, args.Config.Hostname)
+}
+
 // List of built-in collectors. To add one, add its domain name here, and add
 // the same domain in the switch statement above (in factory.Make).
 var builtinCollectors = []string{
 	"autoinc",
+	"aws.aurora",
 	"aws.rds",
+	"binlog",
 	"error.account",
 	"error.global",
 	"error.host",
+	"error.repl",
 	"error.thread",
 	"error.user",
+	"file",
 	"innodb",
 	"innodb.buffer-pool",
+	"lock",
+	"memory",
+	"os",
 	"percona.response-time",
+	"percona.userstat.client",
+	"percona.userstat.index",
+	"percona.userstat.table",
+	"percona.userstat.user",
+	"pfs",
+	"processlist",
+	"proxysql.connection-pool",
+	"proxysql.global",
+	"proxysql.query-digest",
 	"query.response-time",
 	"repl",
 	"repl.lag",
+	"repl.source",
+	"rocksdb",
 	"size.binlog",
 	"size.database",
+	"size.file",
+	"size.index",
 	"size.table",
+	"sql",
+	"status.account",
 	"status.global",
+	"status.host",
+	"status.user",
 	"stmt.current",
+	"stmt.digest",
 	"trx",
 	"tls",
 	"var.global",
+	"wait",
 	"wait.io.table",
 }
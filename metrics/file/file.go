@@ -0,0 +1,266 @@
+// Copyright 2024 Block, Inc.
+
+package file
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DOMAIN = "file"
+
+	OPT_GROUP_BY = "group-by"
+	OPT_INCLUDE  = "include"
+	OPT_EXCLUDE  = "exclude"
+	OPT_TOP      = "top"
+
+	GROUP_BY_FILE  = "file"
+	GROUP_BY_EVENT = "event"
+)
+
+// Metric column index in query column order, after the file and event name columns.
+const (
+	col_count = iota
+	col_latency
+	col_read_count
+	col_read_latency
+	col_read_bytes
+	col_write_count
+	col_write_latency
+	col_write_bytes
+	col_misc_count
+	col_misc_latency
+	n_cols
+)
+
+var columnIndex = map[string]int{
+	"count":         col_count,
+	"latency":       col_latency,
+	"read_count":    col_read_count,
+	"read_latency":  col_read_latency,
+	"read_bytes":    col_read_bytes,
+	"write_count":   col_write_count,
+	"write_latency": col_write_latency,
+	"write_bytes":   col_write_bytes,
+	"misc_count":    col_misc_count,
+	"misc_latency":  col_misc_latency,
+}
+
+// Timer columns are picoseconds, reported as microseconds.
+var timerColumn = [n_cols]bool{
+	col_latency:       true,
+	col_read_latency:  true,
+	col_write_latency: true,
+	col_misc_latency:  true,
+}
+
+type fileConfig struct {
+	query   string
+	params  []interface{}
+	metrics []string
+}
+
+// File collects file I/O from Performance Schema file instrumentation for the
+// file domain.
+// https://dev.mysql.com/doc/refman/8.4/en/performance-schema-file-summary-tables.html
+type File struct {
+	db *sql.DB
+	// --
+	atLevel map[string]*fileConfig
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &File{}
+
+// NewFile makes a new File collector.
+func NewFile(db *sql.DB) *File {
+	return &File{
+		db:      db,
+		atLevel: map[string]*fileConfig{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *File) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *File) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "File I/O by file name or event name (Performance Schema file instrumentation)",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_GROUP_BY: {
+				Name:    OPT_GROUP_BY,
+				Desc:    "Report file I/O by file name or by event name",
+				Default: GROUP_BY_FILE,
+				Values: map[string]string{
+					GROUP_BY_FILE:  "By file name (file_summary_by_instance)",
+					GROUP_BY_EVENT: "By event name, like wait/io/file/innodb/innodb_data_file (file_summary_by_event_name)",
+				},
+			},
+			OPT_INCLUDE: {
+				Name: OPT_INCLUDE,
+				Desc: "Comma-separated list of LIKE patterns of file or event names (per option " + OPT_GROUP_BY + ") to include, like %.ibd (overrides option " + OPT_EXCLUDE + ")",
+			},
+			OPT_EXCLUDE: {
+				Name: OPT_EXCLUDE,
+				Desc: "Comma-separated list of LIKE patterns of file or event names (per option " + OPT_GROUP_BY + ") to exclude (ignored if " + OPT_INCLUDE + " is set)",
+			},
+			OPT_TOP: {
+				Name:    OPT_TOP,
+				Desc:    "Number of top files or events by total latency to report, or 0 for all",
+				Default: "100",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "file", Value: "the file name, or empty string if " + OPT_GROUP_BY + "=" + GROUP_BY_EVENT},
+			{Key: "event", Value: "the file I/O event name"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "count",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Number of file I/O operations (COUNT_STAR)",
+			},
+			{
+				Name: "latency",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Total file I/O latency in microseconds (SUM_TIMER_WAIT)",
+			},
+			{
+				Name: "read_count",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Number of reads (COUNT_READ)",
+			},
+			{
+				Name: "read_latency",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Total read latency in microseconds (SUM_TIMER_READ)",
+			},
+			{
+				Name: "read_bytes",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Bytes read (SUM_NUMBER_OF_BYTES_READ)",
+			},
+			{
+				Name: "write_count",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Number of writes (COUNT_WRITE)",
+			},
+			{
+				Name: "write_latency",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Total write latency in microseconds (SUM_TIMER_WRITE)",
+			},
+			{
+				Name: "write_bytes",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Bytes written (SUM_NUMBER_OF_BYTES_WRITE)",
+			},
+			{
+				Name: "misc_count",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Number of other operations like open, close, and sync (COUNT_MISC)",
+			},
+			{
+				Name: "misc_latency",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Total latency of other operations in microseconds (SUM_TIMER_MISC)",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *File) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+		for _, name := range dom.Metrics {
+			if _, ok := columnIndex[name]; !ok {
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+		}
+
+		top, ok := dom.Options[OPT_TOP]
+		if !ok {
+			top = c.Help().Options[OPT_TOP].Default
+		}
+		n, err := strconv.Atoi(top)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than or equal to zero", OPT_TOP, top)
+		}
+
+		cfg := &fileConfig{
+			metrics: dom.Metrics,
+		}
+		cfg.query, cfg.params, err = FileQuery(dom.Options, n)
+		if err != nil {
+			return nil, err
+		}
+
+		c.atLevel[level.Name] = cfg
+	}
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *File) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	cfg, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	rows, err := c.db.QueryContext(ctx, cfg.query, cfg.params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics []blip.MetricValue
+		file    string
+		event   string
+		vals    [n_cols]float64
+	)
+	dest := []interface{}{&file, &event}
+	for i := range vals {
+		dest = append(dest, &vals[i])
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		group := map[string]string{"file": file, "event": event}
+		for _, name := range cfg.metrics {
+			i := columnIndex[name]
+			v := vals[i]
+			if timerColumn[i] {
+				v /= 1e6 // picoseconds to microseconds
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  name,
+				Type:  blip.CUMULATIVE_COUNTER,
+				Value: v,
+				Group: group,
+			})
+		}
+	}
+
+	return metrics, rows.Err()
+}
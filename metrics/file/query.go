@@ -0,0 +1,66 @@
+// Copyright 2024 Block, Inc.
+
+package file
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	instanceTable  = "performance_schema.file_summary_by_instance"
+	eventNameTable = "performance_schema.file_summary_by_event_name"
+
+	columns = "COUNT_STAR, SUM_TIMER_WAIT, COUNT_READ, SUM_TIMER_READ, SUM_NUMBER_OF_BYTES_READ, COUNT_WRITE, SUM_TIMER_WRITE, SUM_NUMBER_OF_BYTES_WRITE, COUNT_MISC, SUM_TIMER_MISC"
+)
+
+// FileQuery returns the query and params to select file I/O by file name
+// (file_summary_by_instance) or by event name (file_summary_by_event_name),
+// depending on option group-by. Options include and exclude filter on the
+// grouped column. Rows are ordered by total wait time, descending, limited to
+// top rows if top is greater than zero. Files and events without I/O are not
+// selected.
+func FileQuery(set map[string]string, top int) (string, []interface{}, error) {
+	var table, nameCol, fileCol string
+	groupBy := set[OPT_GROUP_BY]
+	switch groupBy {
+	case GROUP_BY_FILE, "":
+		table, nameCol, fileCol = instanceTable, "FILE_NAME", "FILE_NAME"
+	case GROUP_BY_EVENT:
+		table, nameCol, fileCol = eventNameTable, "EVENT_NAME", "''"
+	default:
+		return "", nil, fmt.Errorf("invalid %s value: %s (valid values: %s, %s)", OPT_GROUP_BY, groupBy, GROUP_BY_FILE, GROUP_BY_EVENT)
+	}
+
+	var where string
+	var params []interface{}
+	if include := set[OPT_INCLUDE]; include != "" {
+		where, params = setWhere(nameCol, strings.Split(include, ","), true)
+	} else if exclude := set[OPT_EXCLUDE]; exclude != "" {
+		where, params = setWhere(nameCol, strings.Split(exclude, ","), false)
+	}
+
+	q := fmt.Sprintf("SELECT %s, EVENT_NAME, %s FROM %s WHERE COUNT_STAR > 0%s ORDER BY SUM_TIMER_WAIT DESC",
+		fileCol, columns, table, where)
+	if top > 0 {
+		q += fmt.Sprintf(" LIMIT %d", top)
+	}
+	return q, params, nil
+}
+
+func setWhere(col string, patterns []string, isInclude bool) (string, []interface{}) {
+	cond := make([]string, len(patterns))
+	params := make([]interface{}, len(patterns))
+	for i := range patterns {
+		params[i] = strings.TrimSpace(patterns[i])
+		if isInclude {
+			cond[i] = col + " LIKE ?"
+		} else {
+			cond[i] = col + " NOT LIKE ?"
+		}
+	}
+	if isInclude {
+		return " AND (" + strings.Join(cond, " OR ") + ")", params
+	}
+	return " AND " + strings.Join(cond, " AND "), params
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Block, Inc.
+
+package file_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip/metrics/file"
+	"github.com/go-test/deep"
+)
+
+func TestFileQuery(t *testing.T) {
+	// Defaults: by file, no filter, no limit
+	got, params, err := file.FileQuery(map[string]string{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "SELECT FILE_NAME, EVENT_NAME, COUNT_STAR, SUM_TIMER_WAIT, COUNT_READ, SUM_TIMER_READ, SUM_NUMBER_OF_BYTES_READ, COUNT_WRITE, SUM_TIMER_WRITE, SUM_NUMBER_OF_BYTES_WRITE, COUNT_MISC, SUM_TIMER_MISC FROM performance_schema.file_summary_by_instance WHERE COUNT_STAR > 0 ORDER BY SUM_TIMER_WAIT DESC"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if len(params) != 0 {
+		t.Errorf("got %d params, expected 0", len(params))
+	}
+
+	// Exclude file patterns, top 10
+	opts := map[string]string{
+		file.OPT_GROUP_BY: file.GROUP_BY_FILE,
+		file.OPT_EXCLUDE:  "%/mysql/%, %/sys/%",
+	}
+	got, params, err = file.FileQuery(opts, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "SELECT FILE_NAME, EVENT_NAME, COUNT_STAR, SUM_TIMER_WAIT, COUNT_READ, SUM_TIMER_READ, SUM_NUMBER_OF_BYTES_READ, COUNT_WRITE, SUM_TIMER_WRITE, SUM_NUMBER_OF_BYTES_WRITE, COUNT_MISC, SUM_TIMER_MISC FROM performance_schema.file_summary_by_instance WHERE COUNT_STAR > 0 AND FILE_NAME NOT LIKE ? AND FILE_NAME NOT LIKE ? ORDER BY SUM_TIMER_WAIT DESC LIMIT 10"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"%/mysql/%", "%/sys/%"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// By event, include overrides exclude
+	opts = map[string]string{
+		file.OPT_GROUP_BY: file.GROUP_BY_EVENT,
+		file.OPT_INCLUDE:  "wait/io/file/innodb/%,wait/io/file/sql/binlog",
+		file.OPT_EXCLUDE:  "wait/io/file/sql/%",
+	}
+	got, params, err = file.FileQuery(opts, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "SELECT '', EVENT_NAME, COUNT_STAR, SUM_TIMER_WAIT, COUNT_READ, SUM_TIMER_READ, SUM_NUMBER_OF_BYTES_READ, COUNT_WRITE, SUM_TIMER_WRITE, SUM_NUMBER_OF_BYTES_WRITE, COUNT_MISC, SUM_TIMER_MISC FROM performance_schema.file_summary_by_event_name WHERE COUNT_STAR > 0 AND (EVENT_NAME LIKE ? OR EVENT_NAME LIKE ?) ORDER BY SUM_TIMER_WAIT DESC LIMIT 5"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"wait/io/file/innodb/%", "wait/io/file/sql/binlog"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Invalid group-by
+	if _, _, err = file.FileQuery(map[string]string{file.OPT_GROUP_BY: "table"}, 0); err == nil {
+		t.Error("no error for invalid group-by, expected an error")
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/event"
 	"github.com/cashapp/blip/sqlutil"
 )
 
@@ -16,10 +18,26 @@ import (
 // https://dev.mysql.com/doc/refman/8.4/en/information-schema-innodb-buffer-pool-stats-table.html
 
 const (
-	DOMAIN  = "innodb.buffer-pool"
-	OPT_ALL = "all"
+	DOMAIN = "innodb.buffer-pool"
+
+	OPT_ALL             = "all"
+	OPT_PER_POOL        = "per-pool"
+	OPT_TABLE_LIMIT     = "table-limit"
+	OPT_TABLE_INTERVAL  = "table-interval"
+	OPT_TABLE_MAX_PAGES = "table-max-pages"
 
 	BASE_QUERY = "SELECT %s FROM information_schema.innodb_buffer_pool_stats"
+
+	// Scanning INNODB_BUFFER_PAGE_LRU reads every page in every buffer pool
+	// before GROUP BY and LIMIT, so it's guarded by option table-max-pages
+	// (see PAGES_QUERY) and run only every table-interval collections
+	PAGES_QUERY = "SELECT SUM(DATABASE_PAGES) FROM information_schema.innodb_buffer_pool_stats"
+	TABLE_QUERY = `SELECT TABLE_NAME, COUNT(*), SUM(IS_OLD = 'YES'), SUM(OLDEST_MODIFICATION > 0)
+		FROM information_schema.INNODB_BUFFER_PAGE_LRU
+		WHERE TABLE_NAME IS NOT NULL
+		GROUP BY TABLE_NAME
+		ORDER BY COUNT(*) DESC
+		LIMIT %d`
 )
 
 var (
@@ -58,6 +76,16 @@ var (
 	}
 
 	columnSum map[string]string
+
+	// Derived metrics and the columns they're calculated from: see Derive
+	derivedColumns = map[string][]string{
+		"hit_ratio":             {"number_pages_get", "number_pages_read"},
+		"young_making_ratio":    {"number_pages_get", "pages_made_young"},
+		"read_ahead_efficiency": {"number_pages_read_ahead", "number_read_ahead_evicted"},
+	}
+
+	// Metrics from TABLE_QUERY, in column order after TABLE_NAME
+	tableMetrics = []string{"table_pages", "table_old_pages", "table_dirty_pages"}
 )
 
 func init() {
@@ -67,10 +95,29 @@ func init() {
 	}
 }
 
+type poolLevel struct {
+	query   string          // BASE_QUERY, or empty if only table metrics
+	metrics map[string]bool // columns, derived, and table metrics to report
+	perPool bool
+
+	// Derived metrics
+	derived bool
+	prev    map[string]map[string]float64 // pool_id => column => value
+
+	// Table metrics
+	tables        bool
+	tableQuery    string
+	tableInterval int
+	tableMaxPages float64 // 0 = no max
+	n             int     // collections at this level, for tableInterval
+	skipped       bool    // last table scan skipped because of tableMaxPages
+}
+
 // BufferPoolStats collections from the information_schema.innodb_buffer_pool_stats table.
 type BufferPoolStats struct {
-	db    *sql.DB
-	query map[string]string
+	db      *sql.DB
+	atLevel map[string]*poolLevel
+	event   event.MonitorReceiver
 }
 
 // Verify collector implements blip.Collector interface.
@@ -79,8 +126,8 @@ var _ blip.Collector = &BufferPoolStats{}
 // NewTable makes a new Table collector,
 func NewBufferPoolStats(db *sql.DB) *BufferPoolStats {
 	return &BufferPoolStats{
-		db:    db,
-		query: map[string]string{},
+		db:      db,
+		atLevel: map[string]*poolLevel{},
 	}
 }
 
@@ -90,10 +137,10 @@ func (t *BufferPoolStats) Domain() string {
 }
 
 // Help returns the output for blip --print-domains.
-func (t BufferPoolStats) Help() blip.CollectorHelp {
+func (t *BufferPoolStats) Help() blip.CollectorHelp {
 	return blip.CollectorHelp{
 		Domain:      DOMAIN,
-		Description: "Buffer Pool Stats (summed over all pools)",
+		Description: "Buffer Pool Stats (summed over all pools, or per pool)",
 		Options: map[string]blip.CollectorHelpOption{
 			OPT_ALL: {
 				Name:    OPT_ALL,
@@ -104,12 +151,76 @@ func (t BufferPoolStats) Help() blip.CollectorHelp {
 					"no":  "Specified metrics",
 				},
 			},
+			OPT_PER_POOL: {
+				Name:    OPT_PER_POOL,
+				Desc:    "Report metrics per buffer pool instance, grouped by pool_id",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Per pool (group by pool_id)",
+					"no":  "Summed over all pools",
+				},
+			},
+			OPT_TABLE_LIMIT: {
+				Name:    OPT_TABLE_LIMIT,
+				Desc:    "Number of tables with the most pages in the buffer pool to report (table metrics only)",
+				Default: "20",
+			},
+			OPT_TABLE_INTERVAL: {
+				Name:    OPT_TABLE_INTERVAL,
+				Desc:    "Collect table metrics every N collections at the level (table metrics only)",
+				Default: "10",
+			},
+			OPT_TABLE_MAX_PAGES: {
+				Name:    OPT_TABLE_MAX_PAGES,
+				Desc:    "Skip table metrics if the buffer pool has more than N data pages because they scan every page, or 0 for no max (table metrics only)",
+				Default: "65536",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "pool_id", Value: "the buffer pool instance ID (only if option " + OPT_PER_POOL + "=yes)"},
+			{Key: "db", Value: "the database name (table metrics only)"},
+			{Key: "tbl", Value: "the table name (table metrics only)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "hit_ratio",
+				Type: blip.GAUGE,
+				Desc: "Ratio of page requests not read from disk since the last collection",
+			},
+			{
+				Name: "young_making_ratio",
+				Type: blip.GAUGE,
+				Desc: "Ratio of page requests that made pages young since the last collection",
+			},
+			{
+				Name: "read_ahead_efficiency",
+				Type: blip.GAUGE,
+				Desc: "Ratio of read-ahead pages not evicted without being accessed since the last collection",
+			},
+			{
+				Name: "table_pages",
+				Type: blip.GAUGE,
+				Desc: "Number of buffer pool pages by table (INNODB_BUFFER_PAGE_LRU)",
+			},
+			{
+				Name: "table_old_pages",
+				Type: blip.GAUGE,
+				Desc: "Number of buffer pool pages in the old sublist of the LRU by table (INNODB_BUFFER_PAGE_LRU)",
+			},
+			{
+				Name: "table_dirty_pages",
+				Type: blip.GAUGE,
+				Desc: "Number of modified (dirty) buffer pool pages by table (INNODB_BUFFER_PAGE_LRU)",
+			},
 		},
 	}
 }
 
 // Prepare prepares the collector for the given plan.
-func (c BufferPoolStats) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+func (c *BufferPoolStats) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	c.event = event.MonitorReceiver{MonitorId: plan.MonitorId}
+	help := c.Help()
+
 LEVEL:
 	for _, level := range plan.Levels {
 		dom, ok := level.Collect[DOMAIN]
@@ -117,59 +228,307 @@ LEVEL:
 			continue LEVEL // not collected at this level
 		}
 
-		sumFields := make([]string, 0, len(dom.Metrics))
+		l := &poolLevel{
+			metrics: map[string]bool{},
+			perPool: blip.Bool(dom.Options[OPT_PER_POOL]),
+			prev:    map[string]map[string]float64{},
+		}
+		columns := map[string]bool{}
+
 		all := strings.ToLower(dom.Options[OPT_ALL])
 		switch all {
 		case "all":
 			for _, column := range columnNames {
-				sumFields = append(sumFields, columnSum[column])
+				columns[column] = true
+				l.metrics[column] = true
 			}
 		default:
 			for _, metric := range dom.Metrics {
-				value, ok := columnSum[metric]
-				if !ok {
-					return nil, fmt.Errorf("invalid metric %q", metric)
+				l.metrics[metric] = true
+				if _, ok := columnSum[metric]; ok {
+					columns[metric] = true
+					continue
+				}
+				if cols, ok := derivedColumns[metric]; ok {
+					l.derived = true
+					for _, column := range cols {
+						columns[column] = true
+					}
+					continue
 				}
+				if isTableMetric(metric) {
+					l.tables = true
+					continue
+				}
+				return nil, fmt.Errorf("invalid metric %q", metric)
+			}
+		}
+
+		if len(columns) > 0 {
+			fields := make([]string, 0, len(columns)+1)
+			if l.perPool {
+				fields = append(fields, "pool_id")
+			}
+			for _, column := range columnNames { // columnNames for consistent order
+				if !columns[column] {
+					continue
+				}
+				if l.perPool {
+					fields = append(fields, column)
+				} else {
+					fields = append(fields, columnSum[column])
+				}
+			}
+			l.query = fmt.Sprintf(BASE_QUERY, strings.Join(fields, ", "))
+			blip.Debug("%s: innodb metrics at %s: %s", plan.MonitorId, level.Name, l.query)
+		}
+
+		if l.tables {
+			limit, ok := dom.Options[OPT_TABLE_LIMIT]
+			if !ok {
+				limit = help.Options[OPT_TABLE_LIMIT].Default
+			}
+			n, err := strconv.Atoi(limit)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than zero", OPT_TABLE_LIMIT, limit)
+			}
+			l.tableQuery = fmt.Sprintf(TABLE_QUERY, n)
+
+			interval, ok := dom.Options[OPT_TABLE_INTERVAL]
+			if !ok {
+				interval = help.Options[OPT_TABLE_INTERVAL].Default
+			}
+			l.tableInterval, err = strconv.Atoi(interval)
+			if err != nil || l.tableInterval < 1 {
+				return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than zero", OPT_TABLE_INTERVAL, interval)
+			}
 
-				sumFields = append(sumFields, value)
+			maxPages, ok := dom.Options[OPT_TABLE_MAX_PAGES]
+			if !ok {
+				maxPages = help.Options[OPT_TABLE_MAX_PAGES].Default
 			}
+			n, err = strconv.Atoi(maxPages)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than or equal to zero", OPT_TABLE_MAX_PAGES, maxPages)
+			}
+			l.tableMaxPages = float64(n)
 		}
 
-		c.query[level.Name] = fmt.Sprintf("SELECT %s FROM information_schema.innodb_buffer_pool_stats", strings.Join(sumFields, ", "))
-		blip.Debug("%s: innodb metrics at %s: %s", plan.MonitorId, level.Name, c.query[level.Name])
+		c.atLevel[level.Name] = l
 	}
 	return nil, nil
 }
 
-func (t BufferPoolStats) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
-	o, ok := t.query[levelName]
+func (t *BufferPoolStats) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	l, ok := t.atLevel[levelName]
 	if !ok {
 		return nil, nil
 	}
 
-	results, err := sqlutil.RowToTypedMap[float64](ctx, t.db, o)
+	var metrics []blip.MetricValue
+
+	if l.query != "" {
+		m, err := t.collectStats(ctx, l)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if l.tables {
+		if l.n%l.tableInterval == 0 {
+			m, err := t.collectTables(ctx, l)
+			if err != nil {
+				return nil, err
+			}
+			metrics = append(metrics, m...)
+		}
+		l.n++
+	}
+
+	return metrics, nil
+}
+
+func (t *BufferPoolStats) collectStats(ctx context.Context, l *poolLevel) ([]blip.MetricValue, error) {
+	rows, err := sqlutil.RowsToMaps(ctx, t.db, l.query)
 	if err != nil {
 		return nil, err
 	}
 
-	metrics := make([]blip.MetricValue, 0, len(results))
-	for name, value := range results {
-		m := blip.MetricValue{
-			Name:  name,
-			Type:  blip.CUMULATIVE_COUNTER,
-			Value: value,
+	var metrics []blip.MetricValue
+	for _, row := range rows {
+		// One row summed over all pools, or one row per pool
+		var group map[string]string
+		poolId := row["pool_id"]
+		if l.perPool {
+			group = map[string]string{"pool_id": poolId}
 		}
 
-		if deltas[name] {
-			m.Type = blip.DELTA_COUNTER
+		cur := make(map[string]float64, len(row))
+		for name, val := range row {
+			if name == "pool_id" {
+				continue
+			}
+			value, ok := sqlutil.Float64(val)
+			if !ok {
+				continue
+			}
+			cur[name] = value
+
+			if !l.metrics[name] {
+				continue // only needed for derived metrics
+			}
+			m := blip.MetricValue{
+				Name:  name,
+				Type:  blip.CUMULATIVE_COUNTER,
+				Value: value,
+				Group: group,
+			}
+
+			if deltas[name] {
+				m.Type = blip.DELTA_COUNTER
+			}
+
+			metrics = append(metrics, m)
 		}
 
-		metrics = append(metrics, m)
+		if l.derived {
+			if prev, ok := l.prev[poolId]; ok {
+				for name, value := range Derive(prev, cur) {
+					if !l.metrics[name] {
+						continue
+					}
+					metrics = append(metrics, blip.MetricValue{
+						Name:  name,
+						Type:  blip.GAUGE,
+						Value: value,
+						Group: group,
+					})
+				}
+			}
+			l.prev[poolId] = cur
+		}
 	}
 
 	return metrics, nil
 }
 
+func (t *BufferPoolStats) collectTables(ctx context.Context, l *poolLevel) ([]blip.MetricValue, error) {
+	if l.tableMaxPages > 0 {
+		var pages float64
+		if err := t.db.QueryRowContext(ctx, PAGES_QUERY).Scan(&pages); err != nil {
+			return nil, err
+		}
+		if pages > l.tableMaxPages {
+			if !l.skipped { // report once, not every interval
+				t.event.Sendf(event.BUFFER_POOL_SCAN_SKIPPED, "table metrics skipped: buffer pool has %.0f data pages, more than %s=%.0f", pages, OPT_TABLE_MAX_PAGES, l.tableMaxPages)
+				l.skipped = true
+			}
+			return nil, nil
+		}
+		l.skipped = false
+	}
+
+	rows, err := t.db.QueryContext(ctx, l.tableQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		name    string
+		values  = make([]float64, len(tableMetrics))
+		dest    = []interface{}{&name}
+		metrics []blip.MetricValue
+	)
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		db, tbl := ParseTableName(name)
+		group := map[string]string{"db": db, "tbl": tbl}
+		for i, metric := range tableMetrics {
+			if !l.metrics[metric] {
+				continue
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  metric,
+				Type:  blip.GAUGE,
+				Value: values[i],
+				Group: group,
+			})
+		}
+	}
+
+	return metrics, rows.Err()
+}
+
+// Derive returns derived metrics calculated from the change in buffer pool
+// counters from prev to cur:
+//
+//	hit_ratio             = 1 - number_pages_read / number_pages_get
+//	young_making_ratio    = pages_made_young / number_pages_get
+//	read_ahead_efficiency = 1 - number_read_ahead_evicted / number_pages_read_ahead
+//
+// Unlike columns hit_rate and young_make_per_thousand_get, which InnoDB resets
+// every time it prints its status, these are exact for the collection interval.
+// A derived metric is not returned if its counters are missing, did not change
+// (no page requests or read-ahead), or decreased (MySQL restarted).
+func Derive(prev, cur map[string]float64) map[string]float64 {
+	delta := func(name string) (float64, bool) {
+		p, ok1 := prev[name]
+		c, ok2 := cur[name]
+		if !ok1 || !ok2 || c < p {
+			return 0, false
+		}
+		return c - p, true
+	}
+
+	derived := map[string]float64{}
+
+	if get, ok := delta("number_pages_get"); ok && get > 0 {
+		if read, ok := delta("number_pages_read"); ok {
+			derived["hit_ratio"] = max(0, 1-read/get)
+		}
+		if young, ok := delta("pages_made_young"); ok {
+			derived["young_making_ratio"] = min(1, young/get)
+		}
+	}
+
+	if readAhead, ok := delta("number_pages_read_ahead"); ok && readAhead > 0 {
+		if evicted, ok := delta("number_read_ahead_evicted"); ok {
+			// Pages evicted in this interval might have been read ahead in
+			// the previous interval, so evicted can be > readAhead
+			derived["read_ahead_efficiency"] = max(0, 1-evicted/readAhead)
+		}
+	}
+
+	return derived
+}
+
+// ParseTableName returns the database and table names from INNODB_BUFFER_PAGE_LRU.TABLE_NAME
+// like "`db`.`tbl`". For internal tables without a database, like "SYS_TABLES", the
+// database name is an empty string.
+func ParseTableName(name string) (string, string) {
+	db, tbl, ok := strings.Cut(name, "`.`")
+	if !ok {
+		return "", strings.Trim(name, "`")
+	}
+	return strings.TrimPrefix(db, "`"), strings.TrimSuffix(tbl, "`")
+}
+
+func isTableMetric(name string) bool {
+	for _, m := range tableMetrics {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
 // deltas is a list of known delta metrics in information_schema.innodb_buffer_pool_stats.
 var deltas = map[string]bool{
 	"pages_made_young_rate":     true,
@@ -0,0 +1,77 @@
+// Copyright 2024 Block, Inc.
+
+package innodbbufferpool_test
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	innodbbufferpool "github.com/cashapp/blip/metrics/innodb.buffer-pool"
+)
+
+func TestDerive(t *testing.T) {
+	prev := map[string]float64{
+		"number_pages_get":          1000,
+		"number_pages_read":         100,
+		"pages_made_young":          50,
+		"number_pages_read_ahead":   20,
+		"number_read_ahead_evicted": 5,
+	}
+	cur := map[string]float64{
+		"number_pages_get":          2000, // +1000
+		"number_pages_read":         150,  // +50
+		"pages_made_young":          150,  // +100
+		"number_pages_read_ahead":   60,   // +40
+		"number_read_ahead_evicted": 15,   // +10
+	}
+	got := innodbbufferpool.Derive(prev, cur)
+	expect := map[string]float64{
+		"hit_ratio":             0.95,
+		"young_making_ratio":    0.1,
+		"read_ahead_efficiency": 0.75,
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// No page requests or read-ahead: nothing to derive
+	got = innodbbufferpool.Derive(cur, cur)
+	if len(got) != 0 {
+		t.Errorf("got derived metrics %v, expected none when counters did not change", got)
+	}
+
+	// Counters reset (MySQL restarted): nothing to derive
+	got = innodbbufferpool.Derive(cur, prev)
+	if len(got) != 0 {
+		t.Errorf("got derived metrics %v, expected none when counters reset", got)
+	}
+
+	// Only counters for hit_ratio
+	got = innodbbufferpool.Derive(
+		map[string]float64{"number_pages_get": 10, "number_pages_read": 0},
+		map[string]float64{"number_pages_get": 20, "number_pages_read": 10},
+	)
+	expect = map[string]float64{"hit_ratio": 0}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestParseTableName(t *testing.T) {
+	tests := []struct {
+		name string
+		db   string
+		tbl  string
+	}{
+		{"`test`.`t1`", "test", "t1"},
+		{"`my.db`.`my.tbl`", "my.db", "my.tbl"},
+		{"SYS_TABLES", "", "SYS_TABLES"},
+	}
+	for _, tt := range tests {
+		db, tbl := innodbbufferpool.ParseTableName(tt.name)
+		if db != tt.db || tbl != tt.tbl {
+			t.Errorf("%s: got db=%s tbl=%s, expected db=%s tbl=%s", tt.name, db, tbl, tt.db, tt.tbl)
+		}
+	}
+}
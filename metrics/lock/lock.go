@@ -0,0 +1,411 @@
+// Copyright 2024 Block, Inc.
+
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	myerr "github.com/go-mysql/errors"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/errors"
+)
+
+const (
+	DOMAIN = "lock"
+
+	ERR_NO_TABLE = "table-not-exist"
+)
+
+const (
+	// One row per (waiting lock, blocking lock) pair, so a waiting thread can
+	// be blocked by several threads, and a blocking thread can block several threads.
+	ROW_LOCK_WAITS_QUERY = `SELECT w.REQUESTING_THREAD_ID, w.BLOCKING_THREAD_ID,
+	COALESCE(TIMESTAMPDIFF(MICROSECOND, t.trx_wait_started, NOW(6)) / 1000000, 0),
+	COALESCE(bt.PROCESSLIST_ID, 0), COALESCE(bt.PROCESSLIST_USER, ''),
+	COALESCE(CONCAT(l.OBJECT_SCHEMA, '.', l.OBJECT_NAME), '')
+	FROM performance_schema.data_lock_waits w
+	LEFT JOIN performance_schema.data_locks l ON l.ENGINE = w.ENGINE AND l.ENGINE_LOCK_ID = w.REQUESTING_ENGINE_LOCK_ID
+	LEFT JOIN information_schema.INNODB_TRX t ON t.trx_id = w.REQUESTING_ENGINE_TRANSACTION_ID
+	LEFT JOIN performance_schema.threads bt ON bt.THREAD_ID = w.BLOCKING_THREAD_ID`
+
+	MDL_WAITS_QUERY = `SELECT ml.OBJECT_TYPE, COUNT(*), COALESCE(MAX(t.PROCESSLIST_TIME), 0)
+	FROM performance_schema.metadata_locks ml
+	LEFT JOIN performance_schema.threads t ON t.THREAD_ID = ml.OWNER_THREAD_ID
+	WHERE ml.LOCK_STATUS = 'PENDING'
+	GROUP BY ml.OBJECT_TYPE`
+)
+
+type lockMetrics struct {
+	rowLocks  bool
+	mdl       bool
+	metrics   map[string]bool
+	errPolicy map[string]*errors.Policy
+	stop      bool
+}
+
+// Row lock metrics from data_lock_waits
+var rowLockMetrics = map[string]bool{
+	"waiting":       true,
+	"max_wait_age":  true,
+	"root_blockers": true,
+}
+
+// Metadata lock metrics from metadata_locks
+var mdlMetrics = map[string]bool{
+	"mdl_waiting":      true,
+	"mdl_max_wait_age": true,
+}
+
+// Lock collects lock wait metrics for the lock domain. The sources are
+// performance_schema.data_lock_waits and data_locks (InnoDB row locks, MySQL 8.0
+// and newer) and performance_schema.metadata_locks (metadata locks).
+type Lock struct {
+	db *sql.DB
+	// --
+	atLevel map[string]*lockMetrics
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &Lock{}
+
+// NewLock makes a new Lock collector.
+func NewLock(db *sql.DB) *Lock {
+	return &Lock{
+		db:      db,
+		atLevel: map[string]*lockMetrics{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *Lock) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *Lock) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Row lock and metadata lock waits, and root blockers",
+		Options:     map[string]blip.CollectorHelpOption{},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "object_type", Value: "the metadata lock object type (mdl_* metrics only), or empty string for all object types"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "thread_id", Value: "the root blocker that blocks the most sessions: performance_schema thread ID (root_blockers metric only)"},
+			{Key: "processlist_id", Value: "the root blocker that blocks the most sessions: processlist ID (root_blockers metric only)"},
+			{Key: "user", Value: "the root blocker that blocks the most sessions: user (root_blockers metric only)"},
+			{Key: "object", Value: "the root blocker that blocks the most sessions: table (db.tbl) of a lock it blocks (root_blockers metric only)"},
+		},
+		Errors: map[string]blip.CollectorHelpError{
+			ERR_NO_TABLE: {
+				Name:    ERR_NO_TABLE,
+				Handles: "MySQL error 1146: Table 'performance_schema.data_lock_waits' doesn't exist (MySQL 5.7)",
+				Default: errors.NewPolicy("").String(),
+			},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "waiting",
+				Type: blip.GAUGE,
+				Desc: "Number of sessions waiting for a row lock",
+			},
+			{
+				Name: "max_wait_age",
+				Type: blip.GAUGE,
+				Desc: "Longest current row lock wait in seconds",
+			},
+			{
+				Name: "root_blockers",
+				Type: blip.GAUGE,
+				Desc: "Number of sessions blocking other sessions that are not waiting for a row lock themselves",
+			},
+			{
+				Name: "mdl_waiting",
+				Type: blip.GAUGE,
+				Desc: "Number of pending metadata lock requests",
+			},
+			{
+				Name: "mdl_max_wait_age",
+				Type: blip.GAUGE,
+				Desc: "Longest current statement time in seconds of sessions waiting for a metadata lock",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *Lock) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected at this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+
+		m := &lockMetrics{
+			metrics: map[string]bool{},
+		}
+		for _, name := range dom.Metrics {
+			switch {
+			case rowLockMetrics[name]:
+				m.rowLocks = true
+			case mdlMetrics[name]:
+				m.mdl = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+			m.metrics[name] = true
+		}
+
+		m.errPolicy = map[string]*errors.Policy{
+			ERR_NO_TABLE: errors.NewPolicy(dom.Errors[ERR_NO_TABLE]),
+		}
+		blip.Debug("error policy: %s=%s", ERR_NO_TABLE, m.errPolicy[ERR_NO_TABLE])
+
+		c.atLevel[level.Name] = m
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *Lock) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	m, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	var metrics []blip.MetricValue
+
+	if m.rowLocks && !m.stop {
+		rowMetrics, err := c.collectRowLocks(ctx, m)
+		if err != nil {
+			if err = c.collectError(err, m); err != nil {
+				return nil, err
+			}
+		}
+		metrics = append(metrics, rowMetrics...)
+	}
+
+	if m.mdl {
+		mdlMetrics, err := c.collectMDL(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, mdlMetrics...)
+	}
+
+	return metrics, nil
+}
+
+func (c *Lock) collectRowLocks(ctx context.Context, m *lockMetrics) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, ROW_LOCK_WAITS_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		waits   []LockWait
+		waiting = map[uint64]bool{}
+		maxAge  float64
+		w       LockWait
+		age     float64
+		b       blocker
+	)
+	blockers := map[uint64]blocker{}
+	for rows.Next() {
+		if err = rows.Scan(&w.Waiter, &w.Blocker, &age, &b.processlistId, &b.user, &b.object); err != nil {
+			return nil, err
+		}
+		waits = append(waits, w)
+		waiting[w.Waiter] = true
+		if age > maxAge {
+			maxAge = age
+		}
+		if _, ok := blockers[w.Blocker]; !ok {
+			blockers[w.Blocker] = b
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var metrics []blip.MetricValue
+	if m.metrics["waiting"] {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "waiting",
+			Type:  blip.GAUGE,
+			Value: float64(len(waiting)),
+		})
+	}
+	if m.metrics["max_wait_age"] {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "max_wait_age",
+			Type:  blip.GAUGE,
+			Value: maxAge,
+		})
+	}
+	if m.metrics["root_blockers"] {
+		roots := RootBlockers(waits)
+		mv := blip.MetricValue{
+			Name:  "root_blockers",
+			Type:  blip.GAUGE,
+			Value: float64(len(roots)),
+		}
+		// Meta is the root blocker that blocks the most sessions. If tied,
+		// the lowest thread ID, which is usually the oldest, so the result is stable.
+		var top uint64
+		n := 0
+		for id, blocked := range roots {
+			if blocked > n || (blocked == n && id < top) {
+				top = id
+				n = blocked
+			}
+		}
+		if n > 0 {
+			b := blockers[top]
+			mv.Meta = map[string]string{
+				"thread_id":      strconv.FormatUint(top, 10),
+				"processlist_id": strconv.FormatUint(b.processlistId, 10),
+				"user":           b.user,
+				"object":         b.object,
+			}
+		}
+		metrics = append(metrics, mv)
+	}
+
+	return metrics, nil
+}
+
+func (c *Lock) collectMDL(ctx context.Context, m *lockMetrics) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, MDL_WAITS_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics    []blip.MetricValue
+		objectType string
+		n          float64
+		age        float64
+		totalN     float64
+		totalAge   float64
+	)
+	add := func(objectType string, n, age float64) {
+		group := map[string]string{"object_type": objectType}
+		if m.metrics["mdl_waiting"] {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "mdl_waiting",
+				Type:  blip.GAUGE,
+				Value: n,
+				Group: group,
+			})
+		}
+		if m.metrics["mdl_max_wait_age"] {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "mdl_max_wait_age",
+				Type:  blip.GAUGE,
+				Value: age,
+				Group: group,
+			})
+		}
+	}
+	for rows.Next() {
+		if err = rows.Scan(&objectType, &n, &age); err != nil {
+			return nil, err
+		}
+		add(objectType, n, age)
+		totalN += n
+		if age > totalAge {
+			totalAge = age
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Always report the total for all object types, even when there are no
+	// waits, so the metrics are zero instead of missing
+	add("", totalN, totalAge)
+
+	return metrics, nil
+}
+
+func (c *Lock) collectError(err error, m *lockMetrics) error {
+	var ep *errors.Policy
+	switch myerr.MySQLErrorCode(err) {
+	case 1146:
+		ep = m.errPolicy[ERR_NO_TABLE]
+	default:
+		return err
+	}
+
+	// Stop trying to collect row lock metrics if error policy retry="stop".
+	// Metadata lock metrics are not affected because that table exists in 5.7.
+	if ep.Retry == errors.POLICY_RETRY_NO {
+		m.stop = true
+	}
+
+	if ep.ReportError() {
+		return err
+	}
+	blip.Debug("error policy=ignore: %v", err)
+	return nil
+}
+
+type blocker struct {
+	processlistId uint64
+	user          string
+	object        string
+}
+
+// LockWait is a thread (Waiter) waiting for a lock held by another thread (Blocker).
+type LockWait struct {
+	Waiter  uint64
+	Blocker uint64
+}
+
+// RootBlockers returns the root blockers in the lock waits: blocking threads that
+// are not waiting themselves. The map is keyed on thread ID, and the value is the
+// number of distinct threads blocked by the root blocker directly or transitively
+// (blocked by a thread that it blocks).
+func RootBlockers(waits []LockWait) map[uint64]int {
+	blocks := map[uint64][]uint64{} // blocker => waiters
+	waiting := map[uint64]bool{}
+	for _, w := range waits {
+		blocks[w.Blocker] = append(blocks[w.Blocker], w.Waiter)
+		waiting[w.Waiter] = true
+	}
+
+	roots := map[uint64]int{}
+	for id := range blocks {
+		if waiting[id] {
+			continue // not root: waiting on another blocker
+		}
+		seen := map[uint64]bool{id: true}
+		queue := []uint64{id}
+		for len(queue) > 0 {
+			b := queue[0]
+			queue = queue[1:]
+			for _, w := range blocks[b] {
+				if seen[w] {
+					continue
+				}
+				seen[w] = true
+				queue = append(queue, w)
+			}
+		}
+		roots[id] = len(seen) - 1 // not counting itself
+	}
+	return roots
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 Block, Inc.
+
+package lock_test
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip/metrics/lock"
+)
+
+func TestRootBlockers(t *testing.T) {
+	// No waits
+	got := lock.RootBlockers(nil)
+	if len(got) != 0 {
+		t.Errorf("got %v, expected no root blockers", got)
+	}
+
+	// Chain: 3 waits on 2, 2 waits on 1; and 4 waits on 1 and 5.
+	// 1 and 5 are root blockers; 2 is not because it's waiting.
+	waits := []lock.LockWait{
+		{Waiter: 3, Blocker: 2},
+		{Waiter: 2, Blocker: 1},
+		{Waiter: 4, Blocker: 1},
+		{Waiter: 4, Blocker: 5},
+	}
+	got = lock.RootBlockers(waits)
+	expect := map[uint64]int{
+		1: 3, // 2, 3, 4
+		5: 1, // 4
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Cycle (deadlock not yet detected) has no root blocker
+	waits = []lock.LockWait{
+		{Waiter: 1, Blocker: 2},
+		{Waiter: 2, Blocker: 1},
+	}
+	got = lock.RootBlockers(waits)
+	if len(got) != 0 {
+		t.Errorf("got %v, expected no root blockers", got)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2024 Block, Inc.
+
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DOMAIN = "memory"
+
+	OPT_INCLUDE = "include"
+	OPT_EXCLUDE = "exclude"
+	OPT_TOP     = "top"
+	OPT_TOTAL   = "total"
+	OPT_BY_USER = "by-user"
+)
+
+// Metric column index in query column order, after the event name column.
+const (
+	col_current_count = iota
+	col_high_count
+	col_current_bytes
+	col_high_bytes
+	n_cols
+)
+
+var columnIndex = map[string]int{
+	"current_count": col_current_count,
+	"high_count":    col_high_count,
+	"current_bytes": col_current_bytes,
+	"high_bytes":    col_high_bytes,
+}
+
+// Only current values are summed for totals: the sum of high-water marks
+// is not meaningful because each occurred at a different time.
+var totalMetric = map[string]bool{
+	"current_count": true,
+	"current_bytes": true,
+}
+
+type memoryConfig struct {
+	query      string
+	params     []interface{}
+	userQuery  string // empty unless by-user=yes
+	userParams []interface{}
+	metrics    []string
+	top        int // 0 = all
+	total      bool
+}
+
+// Memory collects memory usage from Performance Schema memory instrumentation
+// for the memory domain.
+// https://dev.mysql.com/doc/refman/8.4/en/performance-schema-memory-summary-tables.html
+type Memory struct {
+	db *sql.DB
+	// --
+	atLevel map[string]*memoryConfig
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &Memory{}
+
+// NewMemory makes a new Memory collector.
+func NewMemory(db *sql.DB) *Memory {
+	return &Memory{
+		db:      db,
+		atLevel: map[string]*memoryConfig{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *Memory) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *Memory) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Memory usage by event name (Performance Schema memory instrumentation)",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_INCLUDE: {
+				Name: OPT_INCLUDE,
+				Desc: "Comma-separated list of event name prefixes to include, like memory/innodb/ (overrides option " + OPT_EXCLUDE + ")",
+			},
+			OPT_EXCLUDE: {
+				Name: OPT_EXCLUDE,
+				Desc: "Comma-separated list of event name prefixes to exclude (ignored if " + OPT_INCLUDE + " is set)",
+			},
+			OPT_TOP: {
+				Name:    OPT_TOP,
+				Desc:    "Number of top events by current bytes used to report (per user if " + OPT_BY_USER + "=yes), or 0 for all",
+				Default: "0",
+			},
+			OPT_TOTAL: {
+				Name:    OPT_TOTAL,
+				Desc:    "Returns total of current metrics for all events (per user if " + OPT_BY_USER + "=yes)",
+				Default: "yes",
+				Values: map[string]string{
+					"yes": "Includes total of current metrics",
+					"no":  "Excludes total of current metrics",
+				},
+			},
+			OPT_BY_USER: {
+				Name:    OPT_BY_USER,
+				Desc:    "Also report memory usage by user (memory_summary_by_user_by_event_name)",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Report global and per-user memory usage",
+					"no":  "Report only global memory usage",
+				},
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "user", Value: "the user name, or empty string for global memory usage"},
+			{Key: "event", Value: "the memory event name, or empty string for the total"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "current_bytes",
+				Type: blip.GAUGE,
+				Desc: "Bytes currently allocated (CURRENT_NUMBER_OF_BYTES_USED)",
+			},
+			{
+				Name: "high_bytes",
+				Type: blip.GAUGE,
+				Desc: "High-water mark of bytes allocated (HIGH_NUMBER_OF_BYTES_USED)",
+			},
+			{
+				Name: "current_count",
+				Type: blip.GAUGE,
+				Desc: "Allocations not yet freed (CURRENT_COUNT_USED)",
+			},
+			{
+				Name: "high_count",
+				Type: blip.GAUGE,
+				Desc: "High-water mark of allocations not yet freed (HIGH_COUNT_USED)",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *Memory) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+		for _, name := range dom.Metrics {
+			if _, ok := columnIndex[name]; !ok {
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+		}
+
+		cfg := &memoryConfig{
+			metrics: dom.Metrics,
+			total:   dom.Options[OPT_TOTAL] != "no",
+		}
+
+		if top, ok := dom.Options[OPT_TOP]; ok {
+			n, err := strconv.Atoi(top)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than or equal to zero", OPT_TOP, top)
+			}
+			cfg.top = n
+		}
+
+		cfg.query, cfg.params = MemoryQuery(dom.Options, false)
+		if blip.Bool(dom.Options[OPT_BY_USER]) {
+			cfg.userQuery, cfg.userParams = MemoryQuery(dom.Options, true)
+		}
+
+		c.atLevel[level.Name] = cfg
+	}
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *Memory) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	cfg, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	metrics, err := c.collect(ctx, cfg, cfg.query, cfg.params, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.userQuery != "" {
+		userMetrics, err := c.collect(ctx, cfg, cfg.userQuery, cfg.userParams, true)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, userMetrics...)
+	}
+
+	return metrics, nil
+}
+
+func (c *Memory) collect(ctx context.Context, cfg *memoryConfig, query string, params []interface{}, byUser bool) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics []blip.MetricValue
+		user    string
+		event   string
+		vals    [n_cols]float64
+		total   [n_cols]float64
+		n       int
+	)
+	dest := []interface{}{&event, &vals[col_current_count], &vals[col_high_count], &vals[col_current_bytes], &vals[col_high_bytes]}
+	if byUser {
+		dest = append([]interface{}{&user}, dest...)
+	}
+
+	// Rows are ordered by user (if byUser), so when the user changes, report
+	// the total for the previous user and reset the top N count
+	lastUser := ""
+	totals := func(user string) {
+		if !cfg.total {
+			return
+		}
+		for _, name := range cfg.metrics {
+			if !totalMetric[name] {
+				continue
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  name,
+				Type:  blip.GAUGE,
+				Value: total[columnIndex[name]],
+				Group: map[string]string{"user": user, "event": ""},
+			})
+		}
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if byUser && user != lastUser {
+			if n > 0 {
+				totals(lastUser)
+			}
+			lastUser = user
+			n = 0
+			total = [n_cols]float64{}
+		}
+		n++
+		for i := range vals {
+			total[i] += vals[i]
+		}
+		if cfg.top > 0 && n > cfg.top {
+			continue
+		}
+		group := map[string]string{"user": user, "event": event}
+		for _, name := range cfg.metrics {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  name,
+				Type:  blip.GAUGE,
+				Value: vals[columnIndex[name]],
+				Group: group,
+			})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !byUser || n > 0 {
+		totals(lastUser)
+	}
+
+	return metrics, nil
+}
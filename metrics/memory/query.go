@@ -0,0 +1,53 @@
+// Copyright 2024 Block, Inc.
+
+package memory
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	globalTable = "performance_schema.memory_summary_global_by_event_name"
+	userTable   = "performance_schema.memory_summary_by_user_by_event_name"
+
+	columns = "CURRENT_COUNT_USED, HIGH_COUNT_USED, CURRENT_NUMBER_OF_BYTES_USED, HIGH_NUMBER_OF_BYTES_USED"
+)
+
+// MemoryQuery returns the query and params to select memory usage by event name
+// (or by user and event name if byUser is true) filtered by event name prefixes.
+// Rows are ordered by current bytes used, descending (per user if byUser is true),
+// and events that have never used memory are not selected.
+func MemoryQuery(set map[string]string, byUser bool) (string, []interface{}) {
+	var where string
+	var params []interface{}
+	if include := set[OPT_INCLUDE]; include != "" {
+		where, params = setWhere(strings.Split(include, ","), true)
+	} else if exclude := set[OPT_EXCLUDE]; exclude != "" {
+		where, params = setWhere(strings.Split(exclude, ","), false)
+	}
+
+	if byUser {
+		return fmt.Sprintf("SELECT USER, EVENT_NAME, %s FROM %s WHERE USER IS NOT NULL AND HIGH_NUMBER_OF_BYTES_USED > 0%s ORDER BY USER, CURRENT_NUMBER_OF_BYTES_USED DESC",
+			columns, userTable, where), params
+	}
+	return fmt.Sprintf("SELECT EVENT_NAME, %s FROM %s WHERE HIGH_NUMBER_OF_BYTES_USED > 0%s ORDER BY CURRENT_NUMBER_OF_BYTES_USED DESC",
+		columns, globalTable, where), params
+}
+
+func setWhere(prefixes []string, isInclude bool) (string, []interface{}) {
+	cond := make([]string, len(prefixes))
+	params := make([]interface{}, len(prefixes))
+	for i := range prefixes {
+		params[i] = strings.TrimSpace(prefixes[i]) + "%"
+		if isInclude {
+			cond[i] = "EVENT_NAME LIKE ?"
+		} else {
+			cond[i] = "EVENT_NAME NOT LIKE ?"
+		}
+	}
+	if isInclude {
+		return " AND (" + strings.Join(cond, " OR ") + ")", params
+	}
+	return " AND " + strings.Join(cond, " AND "), params
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 Block, Inc.
+
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip/metrics/memory"
+	"github.com/go-test/deep"
+)
+
+func TestMemoryQuery(t *testing.T) {
+	// No filter
+	got, params := memory.MemoryQuery(map[string]string{}, false)
+	expect := "SELECT EVENT_NAME, CURRENT_COUNT_USED, HIGH_COUNT_USED, CURRENT_NUMBER_OF_BYTES_USED, HIGH_NUMBER_OF_BYTES_USED FROM performance_schema.memory_summary_global_by_event_name WHERE HIGH_NUMBER_OF_BYTES_USED > 0 ORDER BY CURRENT_NUMBER_OF_BYTES_USED DESC"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if len(params) != 0 {
+		t.Errorf("got %d params, expected 0", len(params))
+	}
+
+	// Exclude prefixes
+	opts := map[string]string{
+		memory.OPT_EXCLUDE: "memory/performance_schema/, memory/sql/",
+	}
+	got, params = memory.MemoryQuery(opts, false)
+	expect = "SELECT EVENT_NAME, CURRENT_COUNT_USED, HIGH_COUNT_USED, CURRENT_NUMBER_OF_BYTES_USED, HIGH_NUMBER_OF_BYTES_USED FROM performance_schema.memory_summary_global_by_event_name WHERE HIGH_NUMBER_OF_BYTES_USED > 0 AND EVENT_NAME NOT LIKE ? AND EVENT_NAME NOT LIKE ? ORDER BY CURRENT_NUMBER_OF_BYTES_USED DESC"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"memory/performance_schema/%", "memory/sql/%"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Include overrides exclude, by user
+	opts = map[string]string{
+		memory.OPT_INCLUDE: "memory/innodb/",
+		memory.OPT_EXCLUDE: "memory/sql/",
+	}
+	got, params = memory.MemoryQuery(opts, true)
+	expect = "SELECT USER, EVENT_NAME, CURRENT_COUNT_USED, HIGH_COUNT_USED, CURRENT_NUMBER_OF_BYTES_USED, HIGH_NUMBER_OF_BYTES_USED FROM performance_schema.memory_summary_by_user_by_event_name WHERE USER IS NOT NULL AND HIGH_NUMBER_OF_BYTES_USED > 0 AND (EVENT_NAME LIKE ?) ORDER BY USER, CURRENT_NUMBER_OF_BYTES_USED DESC"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"memory/innodb/%"}); diff != nil {
+		t.Error(diff)
+	}
+}
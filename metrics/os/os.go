@@ -0,0 +1,374 @@
+// Copyright 2024 Block, Inc.
+
+package os
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DOMAIN = "os"
+
+	OPT_DEVICES = "devices"
+
+	DEFAULT_PROC_DIR = "/proc"
+
+	MYSQL_PATHS_QUERY = "SELECT @@datadir, @@pid_file"
+)
+
+// Metrics by source. The source is the prefix of the metric name: "cpu",
+// "mem", and so on.
+var (
+	cpuMetrics = map[string]string{ // metric => cpuFields
+		"cpu_user":    "user",
+		"cpu_nice":    "nice",
+		"cpu_system":  "system",
+		"cpu_idle":    "idle",
+		"cpu_iowait":  "iowait",
+		"cpu_irq":     "irq",
+		"cpu_softirq": "softirq",
+		"cpu_steal":   "steal",
+	}
+	memMetrics = map[string]string{ // metric => /proc/meminfo field
+		"mem_total":     "MemTotal",
+		"mem_free":      "MemFree",
+		"mem_available": "MemAvailable",
+		"mem_buffers":   "Buffers",
+		"mem_cached":    "Cached",
+		"swap_total":    "SwapTotal",
+		"swap_free":     "SwapFree",
+	}
+	diskMetrics = map[string]func(Diskstats) (float64, byte){
+		"disk_reads":            func(d Diskstats) (float64, byte) { return d.Reads, blip.CUMULATIVE_COUNTER },
+		"disk_read_bytes":       func(d Diskstats) (float64, byte) { return d.ReadBytes, blip.CUMULATIVE_COUNTER },
+		"disk_writes":           func(d Diskstats) (float64, byte) { return d.Writes, blip.CUMULATIVE_COUNTER },
+		"disk_write_bytes":      func(d Diskstats) (float64, byte) { return d.WriteBytes, blip.CUMULATIVE_COUNTER },
+		"disk_io_in_progress":   func(d Diskstats) (float64, byte) { return d.InProgress, blip.GAUGE },
+		"disk_io_time":          func(d Diskstats) (float64, byte) { return d.IOTime, blip.CUMULATIVE_COUNTER },
+		"disk_io_weighted_time": func(d Diskstats) (float64, byte) { return d.WeightedIOTime, blip.CUMULATIVE_COUNTER },
+	}
+	fsMetrics = map[string]bool{
+		"fs_size":        true,
+		"fs_free":        true,
+		"fs_used":        true,
+		"fs_inodes":      true,
+		"fs_inodes_free": true,
+	}
+	mysqldMetrics = map[string]string{ // metric => ReadProcess key
+		"mysqld_rss":     "rss",
+		"mysqld_threads": "threads",
+		"mysqld_fds":     "fds",
+	}
+)
+
+type osMetrics struct {
+	cpu     bool
+	mem     bool
+	disk    bool
+	fs      bool
+	mysqld  bool
+	metrics map[string]bool
+	devices map[string]bool // nil = all except loop and ram devices
+}
+
+// OSArgs are the arguments to NewOS.
+type OSArgs struct {
+	DB    *sql.DB
+	Local bool // true if MySQL is on the same host (see Local)
+
+	// Optional:
+	Proc   fs.FS                                          // default os.DirFS(DEFAULT_PROC_DIR)
+	Statfs func(path string, buf *syscall.Statfs_t) error // default syscall.Statfs
+}
+
+// OS collects host operating system metrics for the os domain. The sources are
+// /proc files, statfs on @@datadir, and /proc/<pid> for mysqld (@@pid_file).
+// Therefore, MySQL must be local (on the same host as Blip), and the host must
+// be Linux.
+type OS struct {
+	db     *sql.DB
+	local  bool
+	proc   fs.FS
+	statfs func(string, *syscall.Statfs_t) error
+	// --
+	atLevel map[string]osMetrics
+	datadir string
+	pidFile string
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &OS{}
+
+// NewOS makes a new OS collector.
+func NewOS(args OSArgs) *OS {
+	c := &OS{
+		db:      args.DB,
+		local:   args.Local,
+		proc:    args.Proc,
+		statfs:  args.Statfs,
+		atLevel: map[string]osMetrics{},
+	}
+	if c.proc == nil {
+		c.proc = os.DirFS(DEFAULT_PROC_DIR)
+	}
+	if c.statfs == nil {
+		c.statfs = syscall.Statfs
+	}
+	return c
+}
+
+// Local returns true if the monitor connects to MySQL on the same host: by
+// socket, or by hostname localhost or a loopback IP.
+func Local(cfg blip.ConfigMonitor) bool {
+	if cfg.Socket != "" {
+		return true
+	}
+	host := cfg.Hostname
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *OS) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *OS) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Host CPU, memory, disk, and datadir filesystem; mysqld process (local MySQL on Linux only)",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_DEVICES: {
+				Name: OPT_DEVICES,
+				Desc: "Comma-separated list of disk devices for disk_* metrics (default: all except loop and ram devices)",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "device", Value: "the disk device name, like sda (disk_* metrics only)"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "path", Value: "the MySQL datadir (fs_* metrics only)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{Name: "cpu_user", Type: blip.CUMULATIVE_COUNTER, Desc: "CPU time in user mode (seconds)"},
+			{Name: "cpu_nice", Type: blip.CUMULATIVE_COUNTER, Desc: "CPU time in user mode with low priority (seconds)"},
+			{Name: "cpu_system", Type: blip.CUMULATIVE_COUNTER, Desc: "CPU time in system mode (seconds)"},
+			{Name: "cpu_idle", Type: blip.CUMULATIVE_COUNTER, Desc: "CPU time idle (seconds)"},
+			{Name: "cpu_iowait", Type: blip.CUMULATIVE_COUNTER, Desc: "CPU time waiting for I/O (seconds)"},
+			{Name: "cpu_irq", Type: blip.CUMULATIVE_COUNTER, Desc: "CPU time servicing interrupts (seconds)"},
+			{Name: "cpu_softirq", Type: blip.CUMULATIVE_COUNTER, Desc: "CPU time servicing softirqs (seconds)"},
+			{Name: "cpu_steal", Type: blip.CUMULATIVE_COUNTER, Desc: "CPU time stolen by the hypervisor (seconds)"},
+			{Name: "mem_total", Type: blip.GAUGE, Desc: "Total memory (bytes)"},
+			{Name: "mem_free", Type: blip.GAUGE, Desc: "Free memory (bytes)"},
+			{Name: "mem_available", Type: blip.GAUGE, Desc: "Available memory (bytes)"},
+			{Name: "mem_buffers", Type: blip.GAUGE, Desc: "Memory used by buffers (bytes)"},
+			{Name: "mem_cached", Type: blip.GAUGE, Desc: "Memory used by the page cache (bytes)"},
+			{Name: "swap_total", Type: blip.GAUGE, Desc: "Total swap (bytes)"},
+			{Name: "swap_free", Type: blip.GAUGE, Desc: "Free swap (bytes)"},
+			{Name: "disk_reads", Type: blip.CUMULATIVE_COUNTER, Desc: "Reads completed"},
+			{Name: "disk_read_bytes", Type: blip.CUMULATIVE_COUNTER, Desc: "Bytes read"},
+			{Name: "disk_writes", Type: blip.CUMULATIVE_COUNTER, Desc: "Writes completed"},
+			{Name: "disk_write_bytes", Type: blip.CUMULATIVE_COUNTER, Desc: "Bytes written"},
+			{Name: "disk_io_in_progress", Type: blip.GAUGE, Desc: "I/Os currently in progress"},
+			{Name: "disk_io_time", Type: blip.CUMULATIVE_COUNTER, Desc: "Time spent doing I/Os (seconds)"},
+			{Name: "disk_io_weighted_time", Type: blip.CUMULATIVE_COUNTER, Desc: "Weighted time spent doing I/Os (seconds)"},
+			{Name: "fs_size", Type: blip.GAUGE, Desc: "Size of the datadir filesystem (bytes)"},
+			{Name: "fs_free", Type: blip.GAUGE, Desc: "Free space available to MySQL on the datadir filesystem (bytes)"},
+			{Name: "fs_used", Type: blip.GAUGE, Desc: "Used space on the datadir filesystem (bytes)"},
+			{Name: "fs_inodes", Type: blip.GAUGE, Desc: "Number of inodes on the datadir filesystem"},
+			{Name: "fs_inodes_free", Type: blip.GAUGE, Desc: "Number of free inodes on the datadir filesystem"},
+			{Name: "mysqld_rss", Type: blip.GAUGE, Desc: "mysqld resident set size (bytes)"},
+			{Name: "mysqld_threads", Type: blip.GAUGE, Desc: "Number of mysqld threads"},
+			{Name: "mysqld_fds", Type: blip.GAUGE, Desc: "Number of mysqld open file descriptors"},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *OS) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	needPaths := false
+
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected at this level
+		}
+
+		if !c.local {
+			return nil, fmt.Errorf("MySQL is not local: the %s domain requires a monitor that connects by socket or to localhost", DOMAIN)
+		}
+		if runtime.GOOS != "linux" {
+			return nil, fmt.Errorf("the %s domain requires Linux, not %s", DOMAIN, runtime.GOOS)
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+
+		m := osMetrics{
+			metrics: map[string]bool{},
+		}
+		for _, name := range dom.Metrics {
+			switch {
+			case cpuMetrics[name] != "":
+				m.cpu = true
+			case memMetrics[name] != "":
+				m.mem = true
+			case diskMetrics[name] != nil:
+				m.disk = true
+			case fsMetrics[name]:
+				m.fs = true
+			case mysqldMetrics[name] != "":
+				m.mysqld = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+			m.metrics[name] = true
+		}
+		if m.fs || m.mysqld {
+			needPaths = true
+		}
+
+		if devices := dom.Options[OPT_DEVICES]; devices != "" {
+			m.devices = map[string]bool{}
+			for _, d := range strings.Split(devices, ",") {
+				m.devices[strings.TrimSpace(d)] = true
+			}
+		}
+
+		c.atLevel[level.Name] = m
+	}
+
+	if needPaths {
+		if err := c.db.QueryRowContext(ctx, MYSQL_PATHS_QUERY).Scan(&c.datadir, &c.pidFile); err != nil {
+			return nil, fmt.Errorf("%s failed: %s", MYSQL_PATHS_QUERY, err)
+		}
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *OS) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	m, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	metrics := []blip.MetricValue{}
+
+	if m.cpu {
+		cpu, err := ReadCPU(c.proc)
+		if err != nil {
+			return nil, err
+		}
+		for name, field := range cpuMetrics {
+			if v, ok := cpu[field]; ok && m.metrics[name] {
+				metrics = append(metrics, blip.MetricValue{Name: name, Type: blip.CUMULATIVE_COUNTER, Value: v})
+			}
+		}
+	}
+
+	if m.mem {
+		mem, err := ReadMeminfo(c.proc)
+		if err != nil {
+			return nil, err
+		}
+		for name, field := range memMetrics {
+			if v, ok := mem[field]; ok && m.metrics[name] {
+				metrics = append(metrics, blip.MetricValue{Name: name, Type: blip.GAUGE, Value: v})
+			}
+		}
+	}
+
+	if m.disk {
+		disks, err := ReadDiskstats(c.proc)
+		if err != nil {
+			return nil, err
+		}
+		for device, d := range disks {
+			if m.devices != nil {
+				if !m.devices[device] {
+					continue
+				}
+			} else if strings.HasPrefix(device, "loop") || strings.HasPrefix(device, "ram") {
+				continue
+			}
+			for name, f := range diskMetrics {
+				if !m.metrics[name] {
+					continue
+				}
+				v, t := f(d)
+				metrics = append(metrics, blip.MetricValue{
+					Name:  name,
+					Type:  t,
+					Value: v,
+					Group: map[string]string{"device": device},
+				})
+			}
+		}
+	}
+
+	if m.fs {
+		var st syscall.Statfs_t
+		if err := c.statfs(c.datadir, &st); err != nil {
+			return nil, fmt.Errorf("statfs %s: %s", c.datadir, err)
+		}
+		bsize := float64(st.Bsize)
+		values := map[string]float64{
+			"fs_size":        float64(st.Blocks) * bsize,
+			"fs_free":        float64(st.Bavail) * bsize, // available to unprivileged users like mysql
+			"fs_used":        float64(st.Blocks-st.Bfree) * bsize,
+			"fs_inodes":      float64(st.Files),
+			"fs_inodes_free": float64(st.Ffree),
+		}
+		meta := map[string]string{"path": c.datadir}
+		for name, v := range values {
+			if m.metrics[name] {
+				metrics = append(metrics, blip.MetricValue{Name: name, Type: blip.GAUGE, Value: v, Meta: meta})
+			}
+		}
+	}
+
+	if m.mysqld {
+		// Read pid file every time because mysqld pid changes on restart
+		data, err := os.ReadFile(c.pidFile)
+		if err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid in %s: %s", c.pidFile, err)
+		}
+		proc, err := ReadProcess(c.proc, pid)
+		if err != nil {
+			return nil, err
+		}
+		for name, key := range mysqldMetrics {
+			if m.metrics[name] {
+				metrics = append(metrics, blip.MetricValue{Name: name, Type: blip.GAUGE, Value: proc[key]})
+			}
+		}
+	}
+
+	return metrics, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 Block, Inc.
+
+package os
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// USER_HZ is the unit of /proc/stat CPU times. It's 100 on all Linux
+// platforms that MySQL supports.
+const USER_HZ = 100
+
+// /proc/stat cpu line fields, in order after "cpu".
+var cpuFields = []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal"}
+
+// ReadCPU reads total CPU time in seconds from /proc/stat, keyed on the
+// cpuFields: "user", "system", and so on.
+func ReadCPU(proc fs.FS) (map[string]float64, error) {
+	data, err := fs.ReadFile(proc, "stat")
+	if err != nil {
+		return nil, err
+	}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 || f[0] != "cpu" {
+			continue
+		}
+		cpu := make(map[string]float64, len(cpuFields))
+		for i, name := range cpuFields {
+			if i+1 >= len(f) {
+				break // older kernels don't have all fields
+			}
+			v, err := strconv.ParseFloat(f[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid /proc/stat cpu %s value: %s: %s", name, f[i+1], err)
+			}
+			cpu[name] = v / USER_HZ
+		}
+		return cpu, nil
+	}
+	return nil, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+// ReadMeminfo reads /proc/meminfo values, keyed on field name like "MemTotal".
+// Values in kB are converted to bytes.
+func ReadMeminfo(proc fs.FS) (map[string]float64, error) {
+	data, err := fs.ReadFile(proc, "meminfo")
+	if err != nil {
+		return nil, err
+	}
+	return parseKeyValues(data)
+}
+
+// Diskstats are the /proc/diskstats values of one device that Blip reports.
+type Diskstats struct {
+	Reads          float64 // reads completed
+	ReadBytes      float64 // sectors read * 512
+	Writes         float64 // writes completed
+	WriteBytes     float64 // sectors written * 512
+	InProgress     float64 // I/Os currently in progress
+	IOTime         float64 // seconds spent doing I/Os
+	WeightedIOTime float64 // weighted seconds spent doing I/Os
+}
+
+// ReadDiskstats reads /proc/diskstats, keyed on device name like "sda".
+// https://www.kernel.org/doc/Documentation/ABI/testing/procfs-diskstats
+func ReadDiskstats(proc fs.FS) (map[string]Diskstats, error) {
+	data, err := fs.ReadFile(proc, "diskstats")
+	if err != nil {
+		return nil, err
+	}
+	disks := map[string]Diskstats{}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 14 {
+			continue
+		}
+		var v [11]float64
+		for i := range v {
+			if v[i], err = strconv.ParseFloat(f[i+3], 64); err != nil {
+				return nil, fmt.Errorf("invalid /proc/diskstats value for %s: %s: %s", f[2], f[i+3], err)
+			}
+		}
+		disks[f[2]] = Diskstats{
+			Reads:          v[0],
+			ReadBytes:      v[2] * 512,
+			Writes:         v[4],
+			WriteBytes:     v[6] * 512,
+			InProgress:     v[8],
+			IOTime:         v[9] / 1000,
+			WeightedIOTime: v[10] / 1000,
+		}
+	}
+	return disks, nil
+}
+
+// ReadProcess reads /proc/<pid>/status and the number of open files in
+// /proc/<pid>/fd, keyed on metric name: rss (bytes), threads, and fds.
+func ReadProcess(proc fs.FS, pid int) (map[string]float64, error) {
+	data, err := fs.ReadFile(proc, fmt.Sprintf("%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+	status, err := parseKeyValues(data)
+	if err != nil {
+		return nil, err
+	}
+	fds, err := fs.ReadDir(proc, fmt.Sprintf("%d/fd", pid))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]float64{
+		"rss":     status["VmRSS"],
+		"threads": status["Threads"],
+		"fds":     float64(len(fds)),
+	}, nil
+}
+
+// parseKeyValues parses lines like "MemTotal:       16318008 kB" from /proc
+// files. Values in kB are converted to bytes. Non-numeric values are ignored.
+func parseKeyValues(data []byte) (map[string]float64, error) {
+	kv := map[string]float64{}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue
+		}
+		f := strings.Fields(v)
+		if len(f) == 0 {
+			continue
+		}
+		n, err := strconv.ParseFloat(f[0], 64)
+		if err != nil {
+			continue // like "State:  S (sleeping)"
+		}
+		if len(f) > 1 && f[1] == "kB" {
+			n *= 1024
+		}
+		kv[strings.TrimSpace(k)] = n
+	}
+	return kv, s.Err()
+}
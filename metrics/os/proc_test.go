@@ -0,0 +1,185 @@
+// Copyright 2024 Block, Inc.
+
+package os_test
+
+import (
+	"context"
+	"sort"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+	osdomain "github.com/cashapp/blip/metrics/os"
+)
+
+// proc is a fake /proc with fixtures from a real Linux host (trimmed)
+var proc = fstest.MapFS{
+	"stat": {Data: []byte(`cpu  10132153 290696 3084719 46828483 16683 0 25195 0 175628 0
+cpu0 1393280 32966 572056 13205496 4940 0 10553 0 0 0
+intr 1462898 0 0 0
+`)},
+	"meminfo": {Data: []byte(`MemTotal:       16318008 kB
+MemFree:          450708 kB
+MemAvailable:    8394288 kB
+Buffers:          210392 kB
+Cached:          7680220 kB
+SwapTotal:             0 kB
+SwapFree:              0 kB
+HugePages_Total:       0
+`)},
+	"diskstats": {Data: []byte(`   7       0 loop0 58 0 2114 18 0 0 0 0 0 40 18 0 0 0 0 0 0
+ 259       0 nvme0n1 385405 121447 27153658 127358 2546093 1933469 82398842 2209637 0 1348068 2386418 0 0 0 0 27371 49422
+`)},
+	"123/status": {Data: []byte(`Name:	mysqld
+State:	S (sleeping)
+VmRSS:	 1048576 kB
+Threads:	42
+`)},
+	"123/fd/0": {Data: []byte{}},
+	"123/fd/1": {Data: []byte{}},
+	"123/fd/2": {Data: []byte{}},
+}
+
+func TestReadCPU(t *testing.T) {
+	got, err := osdomain.ReadCPU(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]float64{
+		"user":    101321.53,
+		"nice":    2906.96,
+		"system":  30847.19,
+		"idle":    468284.83,
+		"iowait":  166.83,
+		"irq":     0,
+		"softirq": 251.95,
+		"steal":   0,
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestReadMeminfo(t *testing.T) {
+	got, err := osdomain.ReadMeminfo(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["MemTotal"] != 16318008*1024 {
+		t.Errorf("MemTotal = %f, expected %d", got["MemTotal"], 16318008*1024)
+	}
+	if got["HugePages_Total"] != 0 {
+		t.Errorf("HugePages_Total = %f, expected 0", got["HugePages_Total"])
+	}
+}
+
+func TestReadDiskstats(t *testing.T) {
+	got, err := osdomain.ReadDiskstats(proc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]osdomain.Diskstats{
+		"loop0": {Reads: 58, ReadBytes: 2114 * 512, IOTime: 0.04, WeightedIOTime: 0.018},
+		"nvme0n1": {
+			Reads:          385405,
+			ReadBytes:      27153658 * 512,
+			Writes:         2546093,
+			WriteBytes:     82398842 * 512,
+			InProgress:     0,
+			IOTime:         1348.068,
+			WeightedIOTime: 2386.418,
+		},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestReadProcess(t *testing.T) {
+	got, err := osdomain.ReadProcess(proc, 123)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]float64{
+		"rss":     1048576 * 1024,
+		"threads": 42,
+		"fds":     3,
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	if _, err := osdomain.ReadProcess(proc, 456); err == nil {
+		t.Error("no error for pid that doesn't exist, expected an error")
+	}
+}
+
+func TestLocal(t *testing.T) {
+	local := []blip.ConfigMonitor{
+		{Socket: "/tmp/mysql.sock"},
+		{Hostname: "localhost"},
+		{Hostname: "127.0.0.1:3306"},
+		{Hostname: "[::1]:3306"},
+	}
+	for _, cfg := range local {
+		if !osdomain.Local(cfg) {
+			t.Errorf("Local(%+v) = false, expected true", cfg)
+		}
+	}
+	remote := []blip.ConfigMonitor{
+		{Hostname: "db1.example.com:3306"},
+		{Hostname: "10.0.0.1"},
+	}
+	for _, cfg := range remote {
+		if osdomain.Local(cfg) {
+			t.Errorf("Local(%+v) = true, expected false", cfg)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	plan := blip.Plan{
+		Levels: map[string]blip.Level{
+			"l1": {
+				Name: "l1",
+				Collect: map[string]blip.Domain{
+					osdomain.DOMAIN: {
+						Metrics: []string{"cpu_iowait", "mem_available", "disk_reads"},
+					},
+				},
+			},
+		},
+	}
+
+	// Not local: Prepare returns an error
+	c := osdomain.NewOS(osdomain.OSArgs{Local: false, Proc: proc})
+	if _, err := c.Prepare(context.Background(), plan); err == nil {
+		t.Error("no error preparing non-local monitor, expected an error")
+	}
+
+	// Local with fake /proc; loop devices are not reported by default
+	c = osdomain.NewOS(osdomain.OSArgs{
+		Local:  true,
+		Proc:   proc,
+		Statfs: func(string, *syscall.Statfs_t) error { return nil },
+	})
+	if _, err := c.Prepare(context.Background(), plan); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Collect(context.Background(), "l1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+	expect := []blip.MetricValue{
+		{Name: "cpu_iowait", Type: blip.CUMULATIVE_COUNTER, Value: 166.83},
+		{Name: "disk_reads", Type: blip.CUMULATIVE_COUNTER, Value: 385405, Group: map[string]string{"device": "nvme0n1"}},
+		{Name: "mem_available", Type: blip.GAUGE, Value: 8394288 * 1024},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
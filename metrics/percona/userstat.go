@@ -0,0 +1,479 @@
+// Copyright 2024 Block, Inc.
+
+package percona
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	myerr "github.com/go-mysql/errors"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/errors"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+/*
+Percona Server User Statistics (userstat) are four Information Schema tables
+that are populated only when @@userstat=ON. When userstat is disabled, the tables
+exist but are empty. On MySQL (not Percona Server), the tables don't exist.
+https://docs.percona.com/percona-server/8.0/user-stats.html
+*/
+
+const (
+	DOMAIN_USERSTAT_USER   = "percona.userstat.user"
+	DOMAIN_USERSTAT_CLIENT = "percona.userstat.client"
+	DOMAIN_USERSTAT_TABLE  = "percona.userstat.table"
+	DOMAIN_USERSTAT_INDEX  = "percona.userstat.index"
+)
+
+const (
+	OPT_USERSTAT_ALL     = "all"
+	OPT_USERSTAT_INCLUDE = "include"
+	OPT_USERSTAT_EXCLUDE = "exclude"
+	OPT_USERSTAT_FLUSH   = "flush"
+
+	ERR_USERSTAT_DISABLED = "userstat-disabled"
+
+	userstatQuery = "SELECT @@global.userstat"
+)
+
+// userstatTable defines one userstat table and how its rows become metrics.
+type userstatTable struct {
+	domain         string
+	desc           string
+	table          string
+	flush          string
+	groupCols      []string // table columns
+	groupKeys      []string // Blip group keys, same order as groupCols
+	metrics        []string // lowercase column names
+	gauges         map[string]bool
+	objectFilter   bool   // include/exclude db.table (else include/exclude values of groupCols[0])
+	excludeDefault string // default for OPT_USERSTAT_EXCLUDE
+}
+
+// Columns common to USER_STATISTICS and CLIENT_STATISTICS.
+var userstatConnMetrics = []string{
+	"total_connections",
+	"concurrent_connections",
+	"connected_time",
+	"busy_time",
+	"cpu_time",
+	"bytes_received",
+	"bytes_sent",
+	"binlog_bytes_written",
+	"rows_fetched",
+	"rows_updated",
+	"table_rows_read",
+	"select_commands",
+	"update_commands",
+	"other_commands",
+	"commit_transactions",
+	"rollback_transactions",
+	"denied_connections",
+	"lost_connections",
+	"access_denied",
+	"empty_queries",
+	"total_ssl_connections",
+}
+
+var userstatTables = map[string]userstatTable{
+	DOMAIN_USERSTAT_USER: {
+		domain:    DOMAIN_USERSTAT_USER,
+		desc:      "Percona Server user statistics (INFORMATION_SCHEMA.USER_STATISTICS)",
+		table:     "INFORMATION_SCHEMA.USER_STATISTICS",
+		flush:     "FLUSH USER_STATISTICS",
+		groupCols: []string{"USER"},
+		groupKeys: []string{"user"},
+		metrics:   userstatConnMetrics,
+		gauges:    map[string]bool{"concurrent_connections": true},
+	},
+	DOMAIN_USERSTAT_CLIENT: {
+		domain:    DOMAIN_USERSTAT_CLIENT,
+		desc:      "Percona Server client statistics (INFORMATION_SCHEMA.CLIENT_STATISTICS)",
+		table:     "INFORMATION_SCHEMA.CLIENT_STATISTICS",
+		flush:     "FLUSH CLIENT_STATISTICS",
+		groupCols: []string{"CLIENT"},
+		groupKeys: []string{"client"},
+		metrics:   userstatConnMetrics,
+		gauges:    map[string]bool{"concurrent_connections": true},
+	},
+	DOMAIN_USERSTAT_TABLE: {
+		domain:         DOMAIN_USERSTAT_TABLE,
+		desc:           "Percona Server table statistics (INFORMATION_SCHEMA.TABLE_STATISTICS)",
+		table:          "INFORMATION_SCHEMA.TABLE_STATISTICS",
+		flush:          "FLUSH TABLE_STATISTICS",
+		groupCols:      []string{"TABLE_SCHEMA", "TABLE_NAME"},
+		groupKeys:      []string{"db", "tbl"},
+		metrics:        []string{"rows_read", "rows_changed", "rows_changed_x_indexes"},
+		gauges:         map[string]bool{},
+		objectFilter:   true,
+		excludeDefault: "mysql.*,information_schema.*,performance_schema.*,sys.*",
+	},
+	DOMAIN_USERSTAT_INDEX: {
+		domain:         DOMAIN_USERSTAT_INDEX,
+		desc:           "Percona Server index statistics (INFORMATION_SCHEMA.INDEX_STATISTICS)",
+		table:          "INFORMATION_SCHEMA.INDEX_STATISTICS",
+		flush:          "FLUSH INDEX_STATISTICS",
+		groupCols:      []string{"TABLE_SCHEMA", "TABLE_NAME", "INDEX_NAME"},
+		groupKeys:      []string{"db", "tbl", "idx"},
+		metrics:        []string{"rows_read"},
+		gauges:         map[string]bool{},
+		objectFilter:   true,
+		excludeDefault: "mysql.*,information_schema.*,performance_schema.*,sys.*",
+	},
+}
+
+type userstatConfig struct {
+	query      string
+	params     []interface{}
+	flush      bool
+	metricType byte
+	stop       bool
+	errPolicy  map[string]*errors.Policy
+}
+
+// Userstat collects metrics for the percona.userstat.* domains.
+type Userstat struct {
+	db *sql.DB
+	t  userstatTable
+	// --
+	atLevel map[string]*userstatConfig // keyed on level
+}
+
+var _ blip.Collector = &Userstat{}
+
+func NewUserstatUser(db *sql.DB) *Userstat {
+	return newUserstat(db, DOMAIN_USERSTAT_USER)
+}
+
+func NewUserstatClient(db *sql.DB) *Userstat {
+	return newUserstat(db, DOMAIN_USERSTAT_CLIENT)
+}
+
+func NewUserstatTable(db *sql.DB) *Userstat {
+	return newUserstat(db, DOMAIN_USERSTAT_TABLE)
+}
+
+func NewUserstatIndex(db *sql.DB) *Userstat {
+	return newUserstat(db, DOMAIN_USERSTAT_INDEX)
+}
+
+func newUserstat(db *sql.DB, domain string) *Userstat {
+	return &Userstat{
+		db:      db,
+		t:       userstatTables[domain],
+		atLevel: map[string]*userstatConfig{},
+	}
+}
+
+func (c *Userstat) Domain() string {
+	return c.t.domain
+}
+
+func (c *Userstat) Help() blip.CollectorHelp {
+	h := blip.CollectorHelp{
+		Domain:      c.t.domain,
+		Description: c.t.desc,
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_USERSTAT_ALL: {
+				Name:    OPT_USERSTAT_ALL,
+				Desc:    "Collect all metrics",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "All metrics (ignore metrics list)",
+					"no":  "Specified metrics",
+				},
+			},
+			OPT_USERSTAT_FLUSH: {
+				Name:    OPT_USERSTAT_FLUSH,
+				Desc:    "If statistics should be flushed after each retrieval",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Flush statistics after each retrieval (" + c.t.flush + ")",
+					"no":  "Do not flush statistics after each retrieval",
+				},
+			},
+		},
+		Groups: make([]blip.CollectorKeyValue, len(c.t.groupKeys)),
+		Errors: map[string]blip.CollectorHelpError{
+			ERR_UNKNOWN_TABLE: {
+				Name:    ERR_UNKNOWN_TABLE,
+				Handles: "MySQL error 1109: Unknown table in information_schema (not Percona Server)",
+				Default: errors.NewPolicy("").String(),
+			},
+			ERR_USERSTAT_DISABLED: {
+				Name:    ERR_USERSTAT_DISABLED,
+				Handles: "No statistics because @@userstat=OFF",
+				Default: errors.NewPolicy("").String(),
+			},
+		},
+	}
+	for i := range c.t.groupKeys {
+		h.Groups[i] = blip.CollectorKeyValue{
+			Key:   c.t.groupKeys[i],
+			Value: "the " + strings.ToLower(c.t.groupCols[i]) + " column value",
+		}
+	}
+	if c.t.objectFilter {
+		h.Options[OPT_USERSTAT_INCLUDE] = blip.CollectorHelpOption{
+			Name: OPT_USERSTAT_INCLUDE,
+			Desc: "Comma-separated list of database or table names to include (overrides option " + OPT_USERSTAT_EXCLUDE + ")",
+		}
+		h.Options[OPT_USERSTAT_EXCLUDE] = blip.CollectorHelpOption{
+			Name:    OPT_USERSTAT_EXCLUDE,
+			Desc:    "Comma-separated list of database or table names to exclude (ignored if " + OPT_USERSTAT_INCLUDE + " is set)",
+			Default: c.t.excludeDefault,
+		}
+	} else {
+		h.Options[OPT_USERSTAT_INCLUDE] = blip.CollectorHelpOption{
+			Name: OPT_USERSTAT_INCLUDE,
+			Desc: fmt.Sprintf("Comma-separated list of %ss to include (overrides option %s)", c.t.groupKeys[0], OPT_USERSTAT_EXCLUDE),
+		}
+		h.Options[OPT_USERSTAT_EXCLUDE] = blip.CollectorHelpOption{
+			Name: OPT_USERSTAT_EXCLUDE,
+			Desc: fmt.Sprintf("Comma-separated list of %ss to exclude (ignored if %s is set)", c.t.groupKeys[0], OPT_USERSTAT_INCLUDE),
+		}
+	}
+	h.Metrics = make([]blip.CollectorMetric, len(c.t.metrics))
+	for i, name := range c.t.metrics {
+		h.Metrics[i] = blip.CollectorMetric{
+			Name: name,
+			Type: blip.CUMULATIVE_COUNTER,
+			Desc: "Column " + strings.ToUpper(name),
+		}
+		if c.t.gauges[name] {
+			h.Metrics[i].Type = blip.GAUGE
+		}
+	}
+	return h
+}
+
+func (c *Userstat) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[c.t.domain]
+		if !ok {
+			continue LEVEL
+		}
+		if dom.Options == nil {
+			dom.Options = make(map[string]string)
+		}
+		if _, ok := dom.Options[OPT_USERSTAT_EXCLUDE]; !ok {
+			dom.Options[OPT_USERSTAT_EXCLUDE] = c.t.excludeDefault
+		}
+
+		config := &userstatConfig{}
+		var err error
+		config.query, config.params, err = UserstatQuery(c.t.domain, dom.Options, dom.Metrics)
+		if err != nil {
+			return nil, err
+		}
+
+		if blip.Bool(dom.Options[OPT_USERSTAT_FLUSH]) {
+			config.flush = true
+			config.metricType = blip.DELTA_COUNTER
+		} else {
+			config.flush = false // default
+			config.metricType = blip.CUMULATIVE_COUNTER
+		}
+
+		// Apply custom error policies, if any
+		config.errPolicy = map[string]*errors.Policy{}
+		config.errPolicy[ERR_UNKNOWN_TABLE] = errors.NewPolicy(dom.Errors[ERR_UNKNOWN_TABLE])
+		config.errPolicy[ERR_USERSTAT_DISABLED] = errors.NewPolicy(dom.Errors[ERR_USERSTAT_DISABLED])
+		blip.Debug("error policy: %s=%s %s=%s", ERR_UNKNOWN_TABLE, config.errPolicy[ERR_UNKNOWN_TABLE],
+			ERR_USERSTAT_DISABLED, config.errPolicy[ERR_USERSTAT_DISABLED])
+
+		c.atLevel[level.Name] = config
+	}
+	return nil, nil
+}
+
+func (c *Userstat) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	config, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+	if config.stop {
+		blip.Debug("stopped by previous error")
+		return nil, nil
+	}
+
+	rows, err := c.db.QueryContext(ctx, config.query, config.params...)
+	if err != nil {
+		return c.collectError(err, config, ERR_UNKNOWN_TABLE)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	nGroups := len(c.t.groupCols)
+	groupVals := make([]string, nGroups)
+	values := make([]interface{}, len(cols))
+	for i := range cols {
+		if i < nGroups {
+			values[i] = &groupVals[i]
+		} else {
+			values[i] = new(float64)
+		}
+	}
+
+	var metrics []blip.MetricValue
+	n := 0
+	for rows.Next() {
+		if err = rows.Scan(values...); err != nil {
+			return nil, err
+		}
+		n++
+
+		group := make(map[string]string, nGroups)
+		for i := range groupVals {
+			group[c.t.groupKeys[i]] = groupVals[i]
+		}
+		for i := nGroups; i < len(cols); i++ {
+			m := blip.MetricValue{
+				Name:  strings.ToLower(cols[i]),
+				Type:  config.metricType,
+				Value: *values[i].(*float64),
+				Group: group,
+			}
+			if c.t.gauges[m.Name] {
+				m.Type = blip.GAUGE
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// No stats might be because userstat is disabled, which is an error
+	// handled by error policy. Else, no stats is valid: no activity.
+	if n == 0 {
+		var userstat string
+		if err := c.db.QueryRowContext(ctx, userstatQuery).Scan(&userstat); err == nil {
+			if on, _ := sqlutil.Float64(userstat); on == 0 {
+				return c.collectError(fmt.Errorf("userstat is disabled (@@global.userstat=%s)", userstat), config, ERR_USERSTAT_DISABLED)
+			}
+		}
+	}
+
+	if config.flush {
+		if _, err = c.db.ExecContext(ctx, c.t.flush); err != nil {
+			return nil, err
+		}
+	}
+
+	return metrics, nil
+}
+
+func (c *Userstat) collectError(err error, config *userstatConfig, errName string) ([]blip.MetricValue, error) {
+	if errName == ERR_UNKNOWN_TABLE && myerr.MySQLErrorCode(err) != 1109 {
+		return nil, err
+	}
+	ep := config.errPolicy[errName]
+
+	// Stop trying to collect if error policy retry="stop". This affects
+	// future calls to Collect; don't return yet because we need to check
+	// the metric policy: drop or zero. But there are no values to zero
+	// because metrics are grouped by rows that don't exist, so always drop.
+	if ep.Retry == errors.POLICY_RETRY_NO {
+		config.stop = true
+	}
+
+	// Report
+	var reportedErr error
+	if ep.ReportError() {
+		reportedErr = err
+	} else {
+		blip.Debug("error policy=ignore: %v", err)
+	}
+
+	return nil, reportedErr
+}
+
+// UserstatQuery returns the query and params to select the given metrics
+// (columns) from the userstat table of the given domain.
+func UserstatQuery(domain string, set map[string]string, metrics []string) (string, []interface{}, error) {
+	t, ok := userstatTables[domain]
+	if !ok {
+		return "", nil, fmt.Errorf("invalid domain: %s", domain)
+	}
+
+	columns := append([]string{}, t.groupCols...)
+	if strings.ToLower(set[OPT_USERSTAT_ALL]) == "yes" {
+		columns = append(columns, t.metrics...)
+	} else {
+		if len(metrics) == 0 {
+			return "", nil, fmt.Errorf("no metrics specified, expect at least one collector metric or option %s=yes", OPT_USERSTAT_ALL)
+		}
+	METRIC:
+		for _, metric := range metrics {
+			metric = strings.ToLower(metric)
+			for _, valid := range t.metrics {
+				if metric == valid {
+					columns = append(columns, metric)
+					continue METRIC
+				}
+			}
+			return "", nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", metric)
+		}
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), t.table)
+
+	var list string
+	include := false
+	if list = set[OPT_USERSTAT_INCLUDE]; list != "" {
+		include = true
+	} else {
+		list = set[OPT_USERSTAT_EXCLUDE]
+	}
+	if list == "" {
+		return query, []interface{}{}, nil
+	}
+
+	if !t.objectFilter {
+		vals := strings.Split(list, ",")
+		inOp := "NOT IN"
+		if include {
+			inOp = "IN"
+		}
+		return fmt.Sprintf("%s WHERE %s %s (%s)", query, t.groupCols[0], inOp, sqlutil.PlaceholderList(len(vals))), sqlutil.ToInterfaceArray(vals), nil
+	}
+
+	where := " WHERE "
+	if !include {
+		where += "NOT "
+	}
+	params := []interface{}{}
+	tables := strings.Split(list, ",")
+	for i, tbl := range tables {
+		if strings.Contains(tbl, ".") {
+			dbAndTable := strings.SplitN(tbl, ".", 2)
+			if dbAndTable[1] == "*" {
+				where += "(TABLE_SCHEMA = ?)"
+				params = append(params, dbAndTable[0])
+			} else {
+				where += "(TABLE_SCHEMA = ? AND TABLE_NAME = ?)"
+				params = append(params, dbAndTable[0], dbAndTable[1])
+			}
+		} else {
+			where += "(TABLE_NAME = ?)"
+			params = append(params, tbl)
+		}
+		if i != len(tables)-1 {
+			if include {
+				where += " OR "
+			} else {
+				where += " AND NOT "
+			}
+		}
+	}
+	return query + where, params, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Block, Inc.
+
+package percona_test
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip/metrics/percona"
+)
+
+func TestUserstatQuery(t *testing.T) {
+	// Table stats with default exclude
+	opts := map[string]string{
+		percona.OPT_USERSTAT_EXCLUDE: "mysql.*,sys.*",
+	}
+	got, params, err := percona.UserstatQuery(percona.DOMAIN_USERSTAT_TABLE, opts, []string{"rows_read", "ROWS_CHANGED"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "SELECT TABLE_SCHEMA, TABLE_NAME, rows_read, rows_changed FROM INFORMATION_SCHEMA.TABLE_STATISTICS WHERE NOT (TABLE_SCHEMA = ?) AND NOT (TABLE_SCHEMA = ?)"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"mysql", "sys"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Index stats, include overrides exclude
+	opts = map[string]string{
+		percona.OPT_USERSTAT_INCLUDE: "t1,test.t2",
+		percona.OPT_USERSTAT_EXCLUDE: "mysql.*",
+	}
+	got, params, err = percona.UserstatQuery(percona.DOMAIN_USERSTAT_INDEX, opts, []string{"rows_read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "SELECT TABLE_SCHEMA, TABLE_NAME, INDEX_NAME, rows_read FROM INFORMATION_SCHEMA.INDEX_STATISTICS WHERE (TABLE_NAME = ?) OR (TABLE_SCHEMA = ? AND TABLE_NAME = ?)"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"t1", "test", "t2"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// User stats, exclude users
+	opts = map[string]string{
+		percona.OPT_USERSTAT_EXCLUDE: "root,event_scheduler",
+	}
+	got, params, err = percona.UserstatQuery(percona.DOMAIN_USERSTAT_USER, opts, []string{"busy_time"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "SELECT USER, busy_time FROM INFORMATION_SCHEMA.USER_STATISTICS WHERE USER NOT IN (?, ?)"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"root", "event_scheduler"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Client stats, all metrics and no filter
+	opts = map[string]string{
+		percona.OPT_USERSTAT_ALL: "yes",
+	}
+	got, params, err = percona.UserstatQuery(percona.DOMAIN_USERSTAT_CLIENT, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "SELECT CLIENT, total_connections, concurrent_connections, connected_time, busy_time, cpu_time, bytes_received, bytes_sent, binlog_bytes_written, rows_fetched, rows_updated, table_rows_read, select_commands, update_commands, other_commands, commit_transactions, rollback_transactions, denied_connections, lost_connections, access_denied, empty_queries, total_ssl_connections FROM INFORMATION_SCHEMA.CLIENT_STATISTICS"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if len(params) != 0 {
+		t.Errorf("got %d params, expected 0", len(params))
+	}
+
+	// Invalid metric
+	_, _, err = percona.UserstatQuery(percona.DOMAIN_USERSTAT_INDEX, map[string]string{}, []string{"rows_changed"})
+	if err == nil {
+		t.Error("no error for invalid metric rows_changed, expected an error")
+	}
+
+	// No metrics
+	_, _, err = percona.UserstatQuery(percona.DOMAIN_USERSTAT_USER, map[string]string{}, nil)
+	if err == nil {
+		t.Error("no error for no metrics, expected an error")
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2024 Block, Inc.
+
+package pfs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/event"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	DOMAIN = "pfs"
+
+	ENGINE_STATUS_QUERY = "SHOW ENGINE PERFORMANCE_SCHEMA STATUS"
+	LOST_QUERY          = "SHOW GLOBAL STATUS LIKE 'Performance\\_schema\\_%\\_lost'"
+
+	// Name of the ENGINE_STATUS_QUERY row with total memory used
+	TOTAL_MEMORY = "performance_schema.memory"
+)
+
+type pfsLevel struct {
+	memory      bool
+	tableMemory bool
+	lost        bool
+}
+
+// PFS collects Performance Schema health for the pfs domain: memory used and
+// lost instrumentation counters. Lost counters mean that Performance Schema is
+// too small and other domains based on it report partial data.
+type PFS struct {
+	db *sql.DB
+	// --
+	atLevel map[string]pfsLevel
+	event   event.MonitorReceiver
+	// Lost counter values and whether each was increasing at the last collection
+	*sync.Mutex
+	last       map[string]float64
+	increasing map[string]bool
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &PFS{}
+
+// NewPFS makes a new PFS collector.
+func NewPFS(db *sql.DB) *PFS {
+	return &PFS{
+		db:         db,
+		atLevel:    map[string]pfsLevel{},
+		Mutex:      &sync.Mutex{},
+		increasing: map[string]bool{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *PFS) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *PFS) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Performance Schema memory and lost instrumentation counters",
+		Groups: []blip.CollectorKeyValue{
+			{Key: "table", Value: "the Performance Schema table (table_memory only)"},
+			{Key: "counter", Value: "the lost counter: status variable without Performance_schema_ prefix and _lost suffix, like digest (lost only)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "memory",
+				Type: blip.GAUGE,
+				Desc: "Total memory used by Performance Schema (bytes)",
+			},
+			{
+				Name: "table_memory",
+				Type: blip.GAUGE,
+				Desc: "Memory used by each Performance Schema table (bytes)",
+			},
+			{
+				Name: "lost",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Performance_schema_*_lost status variables",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *PFS) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	c.event = event.MonitorReceiver{MonitorId: plan.MonitorId}
+
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected at this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+		l := pfsLevel{}
+		for _, name := range dom.Metrics {
+			switch name {
+			case "memory":
+				l.memory = true
+			case "table_memory":
+				l.tableMemory = true
+			case "lost":
+				l.lost = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+		}
+		c.atLevel[level.Name] = l
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *PFS) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	l, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	var metrics []blip.MetricValue
+
+	if l.memory || l.tableMemory {
+		m, err := c.collectMemory(ctx, l)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if l.lost {
+		m, err := c.collectLost(ctx)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	return metrics, nil
+}
+
+func (c *PFS) collectMemory(ctx context.Context, l pfsLevel) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, ENGINE_STATUS_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics []blip.MetricValue
+		typ     string
+		name    string
+		status  string
+	)
+	for rows.Next() {
+		if err = rows.Scan(&typ, &name, &status); err != nil {
+			return nil, err
+		}
+		// Rows are <table>.<attribute>, like "events_waits_history.memory",
+		// and the last row is the total: "performance_schema.memory"
+		table, attr, ok := strings.Cut(name, ".")
+		if !ok || attr != "memory" {
+			continue
+		}
+		v, ok := sqlutil.Float64(status)
+		if !ok {
+			continue
+		}
+		if name == TOTAL_MEMORY {
+			if l.memory {
+				metrics = append(metrics, blip.MetricValue{Name: "memory", Value: v, Type: blip.GAUGE})
+			}
+			continue
+		}
+		if l.tableMemory {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "table_memory",
+				Value: v,
+				Type:  blip.GAUGE,
+				Group: map[string]string{"table": table},
+			})
+		}
+	}
+
+	return metrics, rows.Err()
+}
+
+func (c *PFS) collectLost(ctx context.Context) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, LOST_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics []blip.MetricValue
+		name    string
+		val     string
+	)
+	cur := map[string]float64{}
+	for rows.Next() {
+		if err = rows.Scan(&name, &val); err != nil {
+			return nil, err
+		}
+		v, ok := sqlutil.Float64(val)
+		if !ok {
+			continue
+		}
+		counter := strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(name), "performance_schema_"), "_lost")
+		cur[counter] = v
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "lost",
+			Value: v,
+			Type:  blip.CUMULATIVE_COUNTER,
+			Group: map[string]string{"counter": counter},
+		})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	for _, counter := range startedIncreasing(c.last, cur, c.increasing) {
+		c.event.Errorf(event.PFS_LOST, "Performance_schema_%s_lost increased by %.0f to %.0f: Performance Schema metrics are incomplete",
+			counter, cur[counter]-c.last[counter], cur[counter])
+	}
+	c.last = cur
+
+	return metrics, nil
+}
+
+// startedIncreasing returns the lost counters that increased from prev to cur
+// but were not increasing at the last collection, and it updates increasing.
+// Only the start of an increase is returned so that an event is sent once per
+// period of lost instrumentation, not on every collection. prev is nil on the
+// first collection, which is the baseline.
+func startedIncreasing(prev, cur map[string]float64, increasing map[string]bool) []string {
+	if prev == nil {
+		return nil
+	}
+	var started []string
+	for counter, v := range cur {
+		p, ok := prev[counter]
+		inc := ok && v > p
+		if inc && !increasing[counter] {
+			started = append(started, counter)
+		}
+		increasing[counter] = inc
+	}
+	sort.Strings(started)
+	return started
+}
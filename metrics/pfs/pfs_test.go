@@ -0,0 +1,45 @@
+// Copyright 2024 Block, Inc.
+
+package pfs
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestStartedIncreasing(t *testing.T) {
+	increasing := map[string]bool{}
+
+	// First collection is the baseline
+	cur := map[string]float64{"digest": 10, "mutex_classes": 0}
+	if got := startedIncreasing(nil, cur, increasing); got != nil {
+		t.Errorf("got %v on first collection, expected nil", got)
+	}
+
+	// digest started increasing
+	prev := cur
+	cur = map[string]float64{"digest": 15, "mutex_classes": 0}
+	if diff := deep.Equal(startedIncreasing(prev, cur, increasing), []string{"digest"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// digest still increasing: not reported again; mutex_classes started
+	prev = cur
+	cur = map[string]float64{"digest": 20, "mutex_classes": 1}
+	if diff := deep.Equal(startedIncreasing(prev, cur, increasing), []string{"mutex_classes"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Both stopped increasing
+	prev = cur
+	if got := startedIncreasing(prev, cur, increasing); got != nil {
+		t.Errorf("got %v when no counters increased, expected nil", got)
+	}
+
+	// digest started increasing again
+	cur = map[string]float64{"digest": 21, "mutex_classes": 1}
+	if diff := deep.Equal(startedIncreasing(prev, cur, increasing), []string{"digest"}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Block, Inc.
+
+package processlist
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Thread is one row from the processlist.
+type Thread struct {
+	Command string
+	State   string
+	User    string
+	Db      string
+	Time    float64
+}
+
+func (t Thread) value(dim int) string {
+	switch dim {
+	case 0:
+		return t.Command
+	case 1:
+		return t.State
+	case 2:
+		return t.User
+	default:
+		return t.Db
+	}
+}
+
+// ThreadGroup is the number of threads in one group.
+type ThreadGroup struct {
+	Group map[string]string
+	Count float64
+}
+
+// GroupBy parses the group-by option value into a list of bools indexed like
+// the dimensions list.
+func GroupBy(csv string) ([]bool, error) {
+	groupBy := make([]bool, len(dimensions))
+	for _, dim := range strings.Split(csv, ",") {
+		dim = strings.ToLower(strings.TrimSpace(dim))
+		if dim == "" {
+			continue
+		}
+		found := false
+		for i := range dimensions {
+			if dimensions[i] == dim {
+				groupBy[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid %s value: %s (valid values: %s)", OPT_GROUP_BY, dim, strings.Join(dimensions, ", "))
+		}
+	}
+	return groupBy, nil
+}
+
+// GroupThreads counts threads grouped by the given dimensions. If there are
+// more than maxGroups groups, the largest maxGroups-1 groups are returned and
+// the rest are summed into one group with all values set to OTHER.
+func GroupThreads(threads []Thread, groupBy []bool, maxGroups int) []ThreadGroup {
+	counts := map[string]*ThreadGroup{}
+	for _, t := range threads {
+		key := ""
+		for i := range dimensions {
+			if groupBy[i] {
+				key += t.value(i) + "\x00"
+			}
+		}
+		g, ok := counts[key]
+		if !ok {
+			g = &ThreadGroup{Group: map[string]string{}}
+			for i := range dimensions {
+				if groupBy[i] {
+					g.Group[dimensions[i]] = t.value(i)
+				}
+			}
+			counts[key] = g
+		}
+		g.Count++
+	}
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	// Largest groups first, and sort on key for deterministic order of ties
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]].Count == counts[keys[j]].Count {
+			return keys[i] < keys[j]
+		}
+		return counts[keys[i]].Count > counts[keys[j]].Count
+	})
+
+	if len(keys) <= maxGroups {
+		groups := make([]ThreadGroup, len(keys))
+		for i := range keys {
+			groups[i] = *counts[keys[i]]
+		}
+		return groups
+	}
+
+	groups := make([]ThreadGroup, maxGroups)
+	for i := range keys[:maxGroups-1] {
+		groups[i] = *counts[keys[i]]
+	}
+	other := ThreadGroup{Group: map[string]string{}}
+	for i := range dimensions {
+		if groupBy[i] {
+			other.Group[dimensions[i]] = OTHER
+		}
+	}
+	for _, k := range keys[maxGroups-1:] {
+		other.Count += counts[k].Count
+	}
+	groups[maxGroups-1] = other
+	return groups
+}
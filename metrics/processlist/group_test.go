@@ -0,0 +1,69 @@
+// Copyright 2024 Block, Inc.
+
+package processlist
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestGroupBy(t *testing.T) {
+	got, err := GroupBy("command,db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(got, []bool{true, false, false, true}); diff != nil {
+		t.Error(diff)
+	}
+
+	_, err = GroupBy("command,host")
+	if err == nil {
+		t.Error("no error for invalid dimension, expected one")
+	}
+}
+
+func TestGroupThreads(t *testing.T) {
+	threads := []Thread{
+		{Command: "Sleep", User: "app1", Db: "foo"},
+		{Command: "Sleep", User: "app1", Db: "foo"},
+		{Command: "Sleep", User: "app2", Db: "bar"},
+		{Command: "Query", User: "app1", Db: "foo", Time: 3},
+		{Command: "Query", User: "app2", Db: "bar", Time: 1},
+		{Command: "Daemon", User: "event_scheduler"},
+	}
+
+	// Group by command
+	groupBy, _ := GroupBy("command")
+	got := GroupThreads(threads, groupBy, 100)
+	expect := []ThreadGroup{
+		{Group: map[string]string{"command": "Sleep"}, Count: 3},
+		{Group: map[string]string{"command": "Query"}, Count: 2},
+		{Group: map[string]string{"command": "Daemon"}, Count: 1},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Group by command and user, but only 3 groups
+	groupBy, _ = GroupBy("command,user")
+	got = GroupThreads(threads, groupBy, 3)
+	expect = []ThreadGroup{
+		{Group: map[string]string{"command": "Sleep", "user": "app1"}, Count: 2},
+		{Group: map[string]string{"command": "Daemon", "user": "event_scheduler"}, Count: 1},
+		{Group: map[string]string{"command": OTHER, "user": OTHER}, Count: 3},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// No group-by dimensions is one group: all threads
+	groupBy, _ = GroupBy("")
+	got = GroupThreads(threads, groupBy, 1)
+	expect = []ThreadGroup{
+		{Group: map[string]string{}, Count: 6},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
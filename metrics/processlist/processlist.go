@@ -0,0 +1,265 @@
+// Copyright 2024 Block, Inc.
+
+package processlist
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DOMAIN = "processlist"
+
+	OPT_GROUP_BY   = "group-by"
+	OPT_MAX_GROUPS = "max-groups"
+	OPT_SOURCE     = "source"
+
+	SOURCE_AUTO = "auto"
+	SOURCE_PFS  = "pfs"
+	SOURCE_IS   = "is"
+
+	OPT_GROUP_BY_DEFAULT   = "command"
+	OPT_MAX_GROUPS_DEFAULT = "100"
+
+	// OTHER is the group value used for threads in groups beyond the
+	// max-groups limit.
+	OTHER = "__other__"
+)
+
+const (
+	base_query  = "SELECT COMMAND, COALESCE(STATE, ''), COALESCE(USER, ''), COALESCE(DB, ''), COALESCE(TIME, 0) FROM %s WHERE ID != CONNECTION_ID()"
+	pfs_table   = "performance_schema.processlist"
+	is_table    = "information_schema.processlist"
+	check_query = "SELECT 1 FROM performance_schema.processlist LIMIT 1"
+)
+
+// Valid group-by dimensions in column order of base_query.
+var dimensions = []string{"command", "state", "user", "db"}
+
+type plMetrics struct {
+	threads   bool
+	longest   bool
+	sleeping  bool
+	groupBy   []bool // indexed like dimensions
+	maxGroups int
+	query     string
+}
+
+// Processlist collects metrics for the processlist domain.
+// The source is performance_schema.processlist (MySQL 8.0.22 and newer)
+// or information_schema.processlist.
+type Processlist struct {
+	db *sql.DB
+	// --
+	atLevel map[string]plMetrics
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &Processlist{}
+
+// NewProcesslist makes a new Processlist collector.
+func NewProcesslist(db *sql.DB) *Processlist {
+	return &Processlist{
+		db:      db,
+		atLevel: map[string]plMetrics{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *Processlist) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *Processlist) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Processlist thread counts by command, state, user, and database",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_GROUP_BY: {
+				Name:    OPT_GROUP_BY,
+				Desc:    "Comma-separated list of dimensions to group threads by: " + strings.Join(dimensions, ", "),
+				Default: OPT_GROUP_BY_DEFAULT,
+			},
+			OPT_MAX_GROUPS: {
+				Name:    OPT_MAX_GROUPS,
+				Desc:    "Maximum number of distinct groups reported; threads in smaller groups are reported as group " + OTHER,
+				Default: OPT_MAX_GROUPS_DEFAULT,
+			},
+			OPT_SOURCE: {
+				Name:    OPT_SOURCE,
+				Desc:    "Where to collect the processlist from",
+				Default: SOURCE_AUTO,
+				Values: map[string]string{
+					SOURCE_AUTO: "Auto-determine best source",
+					SOURCE_PFS:  pfs_table,
+					SOURCE_IS:   is_table,
+				},
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "command", Value: "the thread command (if grouped by command)"},
+			{Key: "state", Value: "the thread state (if grouped by state)"},
+			{Key: "user", Value: "the thread user (if grouped by user)"},
+			{Key: "db", Value: "the thread default database (if grouped by db)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "threads",
+				Type: blip.GAUGE,
+				Desc: "Number of threads, grouped by option " + OPT_GROUP_BY,
+			},
+			{
+				Name: "longest",
+				Type: blip.GAUGE,
+				Desc: "Time of longest running query in seconds",
+			},
+			{
+				Name: "sleeping",
+				Type: blip.GAUGE,
+				Desc: "Number of sleeping (idle) connections",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *Processlist) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected at this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+
+		m := plMetrics{}
+		for i := range dom.Metrics {
+			switch dom.Metrics[i] {
+			case "threads":
+				m.threads = true
+			case "longest":
+				m.longest = true
+			case "sleeping":
+				m.sleeping = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", dom.Metrics[i])
+			}
+		}
+
+		groupBy, ok := dom.Options[OPT_GROUP_BY]
+		if !ok {
+			groupBy = OPT_GROUP_BY_DEFAULT
+		}
+		var err error
+		m.groupBy, err = GroupBy(groupBy)
+		if err != nil {
+			return nil, err
+		}
+
+		maxGroups, ok := dom.Options[OPT_MAX_GROUPS]
+		if !ok {
+			maxGroups = OPT_MAX_GROUPS_DEFAULT
+		}
+		m.maxGroups, err = strconv.Atoi(maxGroups)
+		if err != nil || m.maxGroups < 1 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than zero", OPT_MAX_GROUPS, maxGroups)
+		}
+
+		table := pfs_table
+		switch dom.Options[OPT_SOURCE] {
+		case SOURCE_PFS:
+		case SOURCE_IS:
+			table = is_table
+		default:
+			// Auto: performance_schema.processlist is new as of MySQL 8.0.22,
+			// and it doesn't hold the global mutex like information_schema
+			if _, err := c.db.ExecContext(ctx, check_query); err != nil {
+				blip.Debug("%s not available, using %s: %s", pfs_table, is_table, err)
+				table = is_table
+			}
+		}
+		m.query = fmt.Sprintf(base_query, table)
+
+		c.atLevel[level.Name] = m
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *Processlist) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	m, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	rows, err := c.db.QueryContext(ctx, m.query)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %s", m.query, err)
+	}
+	defer rows.Close()
+
+	var (
+		threads  []Thread
+		longest  float64
+		sleeping float64
+	)
+	for rows.Next() {
+		var t Thread
+		if err = rows.Scan(&t.Command, &t.State, &t.User, &t.Db, &t.Time); err != nil {
+			return nil, err
+		}
+		switch t.Command {
+		case "Sleep":
+			sleeping++
+		case "Query", "Execute":
+			if t.Time > longest {
+				longest = t.Time
+			}
+		}
+		threads = append(threads, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	metrics := []blip.MetricValue{}
+
+	if m.threads {
+		for _, g := range GroupThreads(threads, m.groupBy, m.maxGroups) {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "threads",
+				Type:  blip.GAUGE,
+				Value: g.Count,
+				Group: g.Group,
+			})
+		}
+	}
+
+	if m.longest {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "longest",
+			Type:  blip.GAUGE,
+			Value: longest,
+		})
+	}
+
+	if m.sleeping {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "sleeping",
+			Type:  blip.GAUGE,
+			Value: sleeping,
+		})
+	}
+
+	return metrics, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2024 Block, Inc.
+
+package proxysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cashapp/blip"
+)
+
+/*
+ProxySQL collectors query the ProxySQL admin interface (default port 6032),
+which speaks the MySQL protocol, so a monitor connects to it like MySQL.
+But it's not MySQL: only the ProxySQL tables can be queried, and @@read_only
+and other MySQL variables are not meaningful. Set heartbeat.disable and
+plans.change.disable for ProxySQL monitors if those are configured for all
+monitors.
+https://proxysql.com/documentation/stats-statistics/
+*/
+
+const (
+	DOMAIN_CONNECTION_POOL = "proxysql.connection-pool"
+	DOMAIN_GLOBAL          = "proxysql.global"
+	DOMAIN_QUERY_DIGEST    = "proxysql.query-digest"
+)
+
+const (
+	CONNECTION_POOL_QUERY = `SELECT hostgroup, srv_host, srv_port, status,
+	ConnUsed, ConnFree, ConnOK, ConnERR, MaxConnUsed, Queries, Bytes_data_sent, Bytes_data_recv, Latency_us
+	FROM stats.stats_mysql_connection_pool`
+)
+
+// Connection pool metrics in query column order, after the first 4 columns.
+var poolMetrics = []struct {
+	name  string
+	mtype byte
+	desc  string
+}{
+	{"conn_used", blip.GAUGE, "Number of connections in use (ConnUsed)"},
+	{"conn_free", blip.GAUGE, "Number of idle connections (ConnFree)"},
+	{"conn_ok", blip.CUMULATIVE_COUNTER, "Number of connections established (ConnOK)"},
+	{"conn_err", blip.CUMULATIVE_COUNTER, "Number of connection errors (ConnERR)"},
+	{"max_conn_used", blip.GAUGE, "Maximum number of connections in use (MaxConnUsed)"},
+	{"queries", blip.CUMULATIVE_COUNTER, "Number of queries routed to the backend (Queries)"},
+	{"bytes_data_sent", blip.CUMULATIVE_COUNTER, "Bytes sent to the backend (Bytes_data_sent)"},
+	{"bytes_data_recv", blip.CUMULATIVE_COUNTER, "Bytes received from the backend (Bytes_data_recv)"},
+	{"latency_us", blip.GAUGE, "Ping time to the backend in microseconds (Latency_us)"},
+}
+
+// ConnectionPool collects metrics for the proxysql.connection-pool domain.
+// The source is stats_mysql_connection_pool.
+type ConnectionPool struct {
+	db      *sql.DB
+	atLevel map[string]map[string]bool // level => metric name => true
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &ConnectionPool{}
+
+// NewConnectionPool makes a new ConnectionPool collector.
+func NewConnectionPool(db *sql.DB) *ConnectionPool {
+	return &ConnectionPool{
+		db:      db,
+		atLevel: map[string]map[string]bool{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN_CONNECTION_POOL const).
+func (c *ConnectionPool) Domain() string {
+	return DOMAIN_CONNECTION_POOL
+}
+
+// Help returns the output for blip --print-domains.
+func (c *ConnectionPool) Help() blip.CollectorHelp {
+	h := blip.CollectorHelp{
+		Domain:      DOMAIN_CONNECTION_POOL,
+		Description: "ProxySQL backend connection pool by hostgroup and backend",
+		Groups: []blip.CollectorKeyValue{
+			{Key: "hostgroup", Value: "the hostgroup ID"},
+			{Key: "backend", Value: "the backend host:port"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "status", Value: "the backend status: ONLINE, SHUNNED, OFFLINE_SOFT, or OFFLINE_HARD (online metric only)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "online",
+				Type: blip.BOOL,
+				Desc: "1=backend status is ONLINE, 0=any other status",
+			},
+		},
+	}
+	for _, m := range poolMetrics {
+		h.Metrics = append(h.Metrics, blip.CollectorMetric{Name: m.name, Type: m.mtype, Desc: m.desc})
+	}
+	return h
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *ConnectionPool) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	valid := map[string]bool{"online": true}
+	for _, m := range poolMetrics {
+		valid[m.name] = true
+	}
+
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN_CONNECTION_POOL]
+		if !ok {
+			continue LEVEL // not collected at this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+		metrics := make(map[string]bool, len(dom.Metrics))
+		for _, name := range dom.Metrics {
+			if !valid[name] {
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+			metrics[name] = true
+		}
+
+		c.atLevel[level.Name] = metrics
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *ConnectionPool) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	keep, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	rows, err := c.db.QueryContext(ctx, CONNECTION_POOL_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		hostgroup string
+		host      string
+		port      string
+		status    string
+		values    = make([]float64, len(poolMetrics))
+		dest      = []interface{}{&hostgroup, &host, &port, &status}
+		metrics   []blip.MetricValue
+	)
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		group := map[string]string{"hostgroup": hostgroup, "backend": host + ":" + port}
+
+		if keep["online"] {
+			online := 0.0
+			if status == "ONLINE" {
+				online = 1
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "online",
+				Type:  blip.BOOL,
+				Value: online,
+				Group: group,
+				Meta:  map[string]string{"status": status},
+			})
+		}
+
+		for i, m := range poolMetrics {
+			if !keep[m.name] {
+				continue
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  m.name,
+				Type:  m.mtype,
+				Value: values[i],
+				Group: group,
+			})
+		}
+	}
+
+	return metrics, rows.Err()
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 Block, Inc.
+
+package proxysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	OPT_GLOBAL_ALL = "all"
+
+	GLOBAL_QUERY = "SELECT Variable_Name, Variable_Value FROM stats.stats_mysql_global"
+)
+
+// Global collects metrics for the proxysql.global domain: global ProxySQL
+// counters like status.global for MySQL. Metric names are lowercase variable
+// names, like "client_connections_connected".
+type Global struct {
+	db   *sql.DB
+	keep map[string]map[string]bool // level => metricName => true
+	all  map[string]bool            // level => true (collect all vars)
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &Global{}
+
+// NewGlobal makes a new Global collector.
+func NewGlobal(db *sql.DB) *Global {
+	return &Global{
+		db:   db,
+		keep: map[string]map[string]bool{},
+		all:  map[string]bool{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN_GLOBAL const).
+func (c *Global) Domain() string {
+	return DOMAIN_GLOBAL
+}
+
+// Help returns the output for blip --print-domains.
+func (c *Global) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN_GLOBAL,
+		Description: "ProxySQL global counters like 'Client_Connections_connected' and 'Questions'",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_GLOBAL_ALL: {
+				Name:    OPT_GLOBAL_ALL,
+				Desc:    "Collect all variables",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Collect all (safe but wasteful)",
+					"no":  "Collect only variables listed in metrics",
+				},
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *Global) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN_GLOBAL]
+		if !ok {
+			continue LEVEL // not collected at this level
+		}
+
+		if blip.Bool(dom.Options[OPT_GLOBAL_ALL]) {
+			c.all[level.Name] = true // collect all vars
+		} else {
+			if len(dom.Metrics) == 0 {
+				return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+			}
+			metrics := make(map[string]bool, len(dom.Metrics))
+			for i := range dom.Metrics {
+				metrics[strings.ToLower(dom.Metrics[i])] = true
+			}
+			c.keep[level.Name] = metrics
+		}
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *Global) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	if !c.all[levelName] && c.keep[levelName] == nil {
+		return nil, nil // not collected at this level
+	}
+
+	rows, err := c.db.QueryContext(ctx, GLOBAL_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := []blip.MetricValue{}
+	filter := !c.all[levelName] // keep all vars or only some?
+
+	var (
+		val  string
+		name string
+		ok   bool
+	)
+	for rows.Next() {
+		if err = rows.Scan(&name, &val); err != nil {
+			return nil, err
+		}
+
+		name = strings.ToLower(name)
+
+		if filter && !c.keep[levelName][name] {
+			continue
+		}
+
+		m := blip.MetricValue{
+			Name: name,
+			Type: blip.CUMULATIVE_COUNTER,
+		}
+		if globalGauge[m.Name] {
+			m.Type = blip.GAUGE
+		}
+
+		m.Value, ok = sqlutil.Float64(val)
+		if !ok {
+			continue
+		}
+
+		metrics = append(metrics, m)
+	}
+
+	return metrics, rows.Err()
+}
+
+// globalGauge are the stats_mysql_global variables that are gauges;
+// all others are cumulative counters.
+var globalGauge = map[string]bool{
+	"active_transactions":          true,
+	"client_connections_connected": true,
+	"client_connections_non_idle":  true,
+	"server_connections_connected": true,
+	"mysql_thread_workers":         true,
+	"mysql_monitor_workers":        true,
+	"connpool_memory_bytes":        true,
+	"query_cache_memory_bytes":     true,
+	"query_cache_entries":          true,
+	"sqlite3_memory_bytes":         true,
+	"mysql_backend_buffers_bytes":  true,
+	"mysql_frontend_buffers_bytes": true,
+	"mysql_session_internal_bytes": true,
+	"stmt_client_active_total":     true,
+	"stmt_client_active_unique":    true,
+	"stmt_server_active_total":     true,
+	"stmt_server_active_unique":    true,
+	"stmt_cached":                  true,
+	"servers_table_version":        true,
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Block, Inc.
+
+package proxysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cashapp/blip"
+)
+
+func TestGlobalPrepare(t *testing.T) {
+	plan := blip.Plan{
+		Levels: map[string]blip.Level{
+			"kpi": {
+				Name: "kpi",
+				Collect: map[string]blip.Domain{
+					DOMAIN_GLOBAL: {Name: DOMAIN_GLOBAL},
+				},
+			},
+		},
+	}
+
+	// No metrics and all=no is an error
+	c := NewGlobal(nil)
+	if _, err := c.Prepare(context.Background(), plan); err == nil {
+		t.Error("no error for empty metrics, expected one")
+	}
+
+	// No metrics but all=yes is ok
+	plan.Levels["kpi"].Collect[DOMAIN_GLOBAL] = blip.Domain{
+		Name:    DOMAIN_GLOBAL,
+		Options: map[string]string{OPT_GLOBAL_ALL: "yes"},
+	}
+	c = NewGlobal(nil)
+	if _, err := c.Prepare(context.Background(), plan); err != nil {
+		t.Error(err)
+	}
+
+	// Level that doesn't collect the domain: no query (db is nil)
+	got, err := c.Collect(context.Background(), "other")
+	if err != nil {
+		t.Error(err)
+	}
+	if got != nil {
+		t.Errorf("got %v, expected no metrics", got)
+	}
+}
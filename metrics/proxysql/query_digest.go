@@ -0,0 +1,244 @@
+// Copyright 2024 Block, Inc.
+
+package proxysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	OPT_DIGEST_TOP         = "top"
+	OPT_DIGEST_ORDER_BY    = "order-by"
+	OPT_DIGEST_TEXT_LENGTH = "digest-text-length"
+
+	ORDER_BY_LATENCY = "latency"
+	ORDER_BY_COUNT   = "count"
+)
+
+// Digests are summed by hostgroup, schema, user, and digest because ProxySQL
+// also keys digests on client_address if mysql-query_digests_track_hostname=true.
+const digestBase = `SELECT hostgroup, schemaname, username, digest, MAX(digest_text),
+	SUM(count_star), SUM(sum_time), MIN(min_time), MAX(max_time), SUM(sum_rows_affected), SUM(sum_rows_sent)
+	FROM stats.stats_mysql_query_digest
+	GROUP BY hostgroup, schemaname, username, digest`
+
+// Digest metrics in query column order, after the first 5 columns.
+var digestMetrics = []struct {
+	name  string
+	mtype byte
+	desc  string
+}{
+	{"count", blip.CUMULATIVE_COUNTER, "Number of queries executed (count_star)"},
+	{"total_latency", blip.CUMULATIVE_COUNTER, "Total query latency in microseconds (sum_time)"},
+	{"min_latency", blip.GAUGE, "Minimum query latency in microseconds (min_time)"},
+	{"max_latency", blip.GAUGE, "Maximum query latency in microseconds (max_time)"},
+	{"rows_affected", blip.CUMULATIVE_COUNTER, "Number of rows affected (sum_rows_affected)"},
+	{"rows_sent", blip.CUMULATIVE_COUNTER, "Number of rows sent (sum_rows_sent)"},
+}
+
+type digestLevel struct {
+	query      string
+	metrics    map[string]bool
+	textLength int
+}
+
+// QueryDigest collects metrics for the proxysql.query-digest domain.
+// The source is stats_mysql_query_digest.
+type QueryDigest struct {
+	db      *sql.DB
+	atLevel map[string]digestLevel
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &QueryDigest{}
+
+// NewQueryDigest makes a new QueryDigest collector.
+func NewQueryDigest(db *sql.DB) *QueryDigest {
+	return &QueryDigest{
+		db:      db,
+		atLevel: map[string]digestLevel{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN_QUERY_DIGEST const).
+func (c *QueryDigest) Domain() string {
+	return DOMAIN_QUERY_DIGEST
+}
+
+// Help returns the output for blip --print-domains.
+func (c *QueryDigest) Help() blip.CollectorHelp {
+	h := blip.CollectorHelp{
+		Domain:      DOMAIN_QUERY_DIGEST,
+		Description: "ProxySQL top query digests by latency or count",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_DIGEST_TOP: {
+				Name:    OPT_DIGEST_TOP,
+				Desc:    "Number of top digests to collect, ordered by option " + OPT_DIGEST_ORDER_BY,
+				Default: "100",
+			},
+			OPT_DIGEST_ORDER_BY: {
+				Name:    OPT_DIGEST_ORDER_BY,
+				Desc:    "How to order digests to select top digests",
+				Default: ORDER_BY_LATENCY,
+				Values: map[string]string{
+					ORDER_BY_LATENCY: "Total latency (sum_time)",
+					ORDER_BY_COUNT:   "Execution count (count_star)",
+				},
+			},
+			OPT_DIGEST_TEXT_LENGTH: {
+				Name:    OPT_DIGEST_TEXT_LENGTH,
+				Desc:    "Maximum length of digest_text meta, or 0 to disable",
+				Default: "100",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "hostgroup", Value: "the hostgroup ID"},
+			{Key: "schema", Value: "the default schema of the query"},
+			{Key: "user", Value: "the user"},
+			{Key: "digest", Value: "the query digest (hash)"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "digest_text", Value: "the normalized query, truncated to option " + OPT_DIGEST_TEXT_LENGTH},
+		},
+	}
+	for _, m := range digestMetrics {
+		h.Metrics = append(h.Metrics, blip.CollectorMetric{Name: m.name, Type: m.mtype, Desc: m.desc})
+	}
+	return h
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *QueryDigest) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	valid := map[string]bool{}
+	for _, m := range digestMetrics {
+		valid[m.name] = true
+	}
+	help := c.Help()
+
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN_QUERY_DIGEST]
+		if !ok {
+			continue LEVEL // not collected at this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+		m := digestLevel{
+			metrics: make(map[string]bool, len(dom.Metrics)),
+		}
+		for _, name := range dom.Metrics {
+			if !valid[name] {
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+			m.metrics[name] = true
+		}
+
+		top, ok := dom.Options[OPT_DIGEST_TOP]
+		if !ok {
+			top = help.Options[OPT_DIGEST_TOP].Default
+		}
+		n, err := strconv.Atoi(top)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than zero", OPT_DIGEST_TOP, top)
+		}
+
+		orderBy, ok := dom.Options[OPT_DIGEST_ORDER_BY]
+		if !ok {
+			orderBy = ORDER_BY_LATENCY
+		}
+		m.query, err = DigestQuery(orderBy, n)
+		if err != nil {
+			return nil, err
+		}
+
+		textLength, ok := dom.Options[OPT_DIGEST_TEXT_LENGTH]
+		if !ok {
+			textLength = help.Options[OPT_DIGEST_TEXT_LENGTH].Default
+		}
+		m.textLength, err = strconv.Atoi(textLength)
+		if err != nil || m.textLength < 0 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than or equal to zero", OPT_DIGEST_TEXT_LENGTH, textLength)
+		}
+
+		c.atLevel[level.Name] = m
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *QueryDigest) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	m, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	rows, err := c.db.QueryContext(ctx, m.query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		hostgroup string
+		schema    string
+		user      string
+		digest    string
+		text      string
+		values    = make([]float64, len(digestMetrics))
+		dest      = []interface{}{&hostgroup, &schema, &user, &digest, &text}
+		metrics   []blip.MetricValue
+	)
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		group := map[string]string{"hostgroup": hostgroup, "schema": schema, "user": user, "digest": digest}
+		var meta map[string]string
+		if m.textLength > 0 {
+			meta = map[string]string{"digest_text": sqlutil.Truncate(text, m.textLength)}
+		}
+
+		for i, dm := range digestMetrics {
+			if !m.metrics[dm.name] {
+				continue
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  dm.name,
+				Type:  dm.mtype,
+				Value: values[i],
+				Group: group,
+				Meta:  meta,
+			})
+		}
+	}
+
+	return metrics, rows.Err()
+}
+
+// DigestQuery returns the query to select the top digests ordered by total
+// latency or count.
+func DigestQuery(orderBy string, top int) (string, error) {
+	var col string
+	switch orderBy {
+	case ORDER_BY_LATENCY:
+		col = "SUM(sum_time)"
+	case ORDER_BY_COUNT:
+		col = "SUM(count_star)"
+	default:
+		return "", fmt.Errorf("invalid %s value: %s (valid values: %s, %s)", OPT_DIGEST_ORDER_BY, orderBy, ORDER_BY_LATENCY, ORDER_BY_COUNT)
+	}
+	return fmt.Sprintf("%s ORDER BY %s DESC LIMIT %d", digestBase, col, top), nil
+}
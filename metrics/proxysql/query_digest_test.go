@@ -0,0 +1,32 @@
+// Copyright 2024 Block, Inc.
+
+package proxysql
+
+import (
+	"testing"
+)
+
+func TestDigestQuery(t *testing.T) {
+	got, err := DigestQuery(ORDER_BY_LATENCY, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := digestBase + " ORDER BY SUM(sum_time) DESC LIMIT 100"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	got, err = DigestQuery(ORDER_BY_COUNT, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = digestBase + " ORDER BY SUM(count_star) DESC LIMIT 5"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	_, err = DigestQuery("rows", 5)
+	if err == nil {
+		t.Error("no error for invalid order-by, expected one")
+	}
+}
@@ -25,9 +25,12 @@ const (
 	OPT_REPORT_NOT_A_REPLICA  = "report-not-a-replica"
 	OPT_DEFAULT_CHANNEL_NAME  = "default-channel-name"
 	OPT_NETWORK_LATENCY       = "network-latency"
+	OPT_PT_INTERVAL           = "pt-interval"
+	OPT_PT_UTC                = "pt-utc"
 
 	LAG_WRITER_BLIP = "blip"
 	LAG_WRITER_PFS  = "pfs"
+	LAG_WRITER_PT   = "pt-heartbeat"
 )
 
 type Lag struct {
@@ -70,20 +73,21 @@ func (c *Lag) Help() blip.CollectorHelp {
 				Desc:    "How to collect Lag",
 				Default: "auto",
 				Values: map[string]string{
-					"auto": "Auto-determine best lag writer",
-					"blip": "Native Blip heartbeat replication lag",
-					"pfs":  "Performance Schema",
+					"auto":         "Auto-determine best lag writer",
+					"blip":         "Native Blip heartbeat replication lag",
+					"pfs":          "Performance Schema",
+					"pt-heartbeat": "Percona Toolkit pt-heartbeat",
 					///"legacy": "Second_Behind_Slave|Replica from SHOW SHOW|REPLICA STATUS",
 				},
 			},
 			OPT_HEARTBEAT_TABLE: {
 				Name:    OPT_HEARTBEAT_TABLE,
-				Desc:    "Heartbeat table",
+				Desc:    "Heartbeat table (" + heartbeat.DEFAULT_PT_HEARTBEAT_TABLE + " if writer is " + LAG_WRITER_PT + ")",
 				Default: blip.DEFAULT_HEARTBEAT_TABLE,
 			},
 			OPT_HEARTBEAT_SOURCE_ID: {
 				Name: OPT_HEARTBEAT_SOURCE_ID,
-				Desc: "Source ID as reported by heartbeat writer; mutually exclusive with " + OPT_HEARTBEAT_SOURCE_ROLE,
+				Desc: "Source ID as reported by heartbeat writer (server_id if writer is " + LAG_WRITER_PT + "); mutually exclusive with " + OPT_HEARTBEAT_SOURCE_ROLE,
 			},
 			OPT_HEARTBEAT_SOURCE_ROLE: {
 				Name: OPT_HEARTBEAT_SOURCE_ROLE,
@@ -120,6 +124,20 @@ func (c *Lag) Help() blip.CollectorHelp {
 				Desc:    "Network latency (milliseconds)",
 				Default: "50",
 			},
+			OPT_PT_INTERVAL: {
+				Name:    OPT_PT_INTERVAL,
+				Desc:    "pt-heartbeat --interval",
+				Default: "1s",
+			},
+			OPT_PT_UTC: {
+				Name:    OPT_PT_UTC,
+				Desc:    "pt-heartbeat --utc",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "pt-heartbeat writes UTC timestamps (--utc)",
+					"no":  "pt-heartbeat writes local timestamps",
+				},
+			},
 		},
 		Metrics: []blip.CollectorMetric{
 			{
@@ -184,6 +202,11 @@ LEVEL:
 			if err != nil {
 				return nil, err
 			}
+		case LAG_WRITER_PT:
+			cleanup, err = c.preparePtHeartbeat(levelName, plan.MonitorId, plan.Name, dom.Options)
+			if err != nil {
+				return nil, err
+			}
 		case "auto", "": // default
 			// Try PFS first
 			if _, err = c.collectPFS(ctx, levelName); err == nil {
@@ -199,7 +222,7 @@ LEVEL:
 				}
 			}
 		default:
-			return nil, fmt.Errorf("invalid lag writer: %q; valid values: auto, pfs, blip", writer)
+			return nil, fmt.Errorf("invalid lag writer: %q; valid values: auto, pfs, blip, %s", writer, LAG_WRITER_PT)
 		}
 
 		c.lagWriterIn[levelName] = writer // collect at this level
@@ -230,7 +253,7 @@ func (c *Lag) verifyReplCheck(ctx context.Context, variable string) error {
 
 func (c *Lag) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
 	switch c.lagWriterIn[levelName] {
-	case LAG_WRITER_BLIP:
+	case LAG_WRITER_BLIP, LAG_WRITER_PT:
 		return c.collectBlip(ctx, levelName)
 	case LAG_WRITER_PFS:
 		return c.collectPFS(ctx, levelName)
@@ -254,15 +277,7 @@ func (c *Lag) prepareBlip(levelName string, monitorID string, planName string, o
 	if table == "" {
 		table = blip.DEFAULT_HEARTBEAT_TABLE
 	}
-	netLatency := 50 * time.Millisecond
-	if s, ok := options[OPT_NETWORK_LATENCY]; ok {
-		n, err := strconv.Atoi(s)
-		if err != nil {
-			blip.Debug("%s: invalid network-latency: %s: %s (ignoring; using default 50 ms)", monitorID, s, err)
-		} else {
-			netLatency = time.Duration(n) * time.Millisecond
-		}
-	}
+	netLatency := networkLatency(monitorID, options)
 	// Only 1 reader per plan
 	c.lagReader = heartbeat.NewBlipReader(heartbeat.BlipReaderArgs{
 		MonitorId:  monitorID,
@@ -287,6 +302,67 @@ func (c *Lag) prepareBlip(levelName string, monitorID string, planName string, o
 	return cleanup, nil
 }
 
+// preparePtHeartbeat is like prepareBlip but creates a reader for pt-heartbeat.
+// The reader is used the same way, so Collect calls collectBlip for both.
+func (c *Lag) preparePtHeartbeat(levelName string, monitorID string, planName string, options map[string]string) (func(), error) {
+	if c.lagReader != nil {
+		return nil, nil
+	}
+
+	if options[OPT_HEARTBEAT_SOURCE_ROLE] != "" {
+		return nil, fmt.Errorf("option %s not supported with writer %s: pt-heartbeat does not write a source role", OPT_HEARTBEAT_SOURCE_ROLE, LAG_WRITER_PT)
+	}
+
+	interval := heartbeat.DEFAULT_PT_HEARTBEAT_INTERVAL
+	if s, ok := options[OPT_PT_INTERVAL]; ok {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be a duration greater than zero", OPT_PT_INTERVAL, s)
+		}
+		interval = d
+	}
+
+	c.dropNoHeartbeat[levelName] = !blip.Bool(options[OPT_REPORT_NO_HEARTBEAT])
+
+	netLatency := networkLatency(monitorID, options)
+	// Only 1 reader per plan
+	c.lagReader = heartbeat.NewPtHeartbeatReader(heartbeat.PtHeartbeatReaderArgs{
+		MonitorId: monitorID,
+		DB:        c.db,
+		Table:     options[OPT_HEARTBEAT_TABLE], // default percona.heartbeat
+		SourceId:  options[OPT_HEARTBEAT_SOURCE_ID],
+		Interval:  interval,
+		UTC:       blip.Bool(options[OPT_PT_UTC]),
+		ReplCheck: c.replCheck,
+		Waiter: heartbeat.SlowFastWaiter{
+			MonitorId:      monitorID,
+			NetworkLatency: netLatency,
+		},
+	})
+	go c.lagReader.Start()
+	blip.Debug("%s: started pt-heartbeat reader: %s/%s (interval: %s, network latency: %s)", monitorID, planName, levelName, interval, netLatency)
+	c.lagWriterIn[levelName] = LAG_WRITER_PT
+	var cleanup func()
+	cleanup = func() {
+		blip.Debug("%s: stopping reader", monitorID)
+		c.lagReader.Stop()
+	}
+	return cleanup, nil
+}
+
+func networkLatency(monitorID string, options map[string]string) time.Duration {
+	netLatency := 50 * time.Millisecond
+	if s, ok := options[OPT_NETWORK_LATENCY]; ok {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			blip.Debug("%s: invalid network-latency: %s: %s (ignoring; using default 50 ms)", monitorID, s, err)
+		} else {
+			netLatency = time.Duration(n) * time.Millisecond
+		}
+	}
+	return netLatency
+}
+
 func (c *Lag) collectBlip(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
 	lag, err := c.lagReader.Lag(ctx)
 	if err != nil {
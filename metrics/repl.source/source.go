@@ -0,0 +1,321 @@
+// Copyright 2024 Block, Inc.
+
+package replsource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/metrics/repl"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	DOMAIN = "repl.source"
+
+	// Binlog dump threads, one per connected replica (also mysqlbinlog
+	// --read-from-remote-server and other binlog clients).
+	BINLOG_DUMP_QUERY = `SELECT PROCESSLIST_ID, COALESCE(PROCESSLIST_HOST, ''), COALESCE(PROCESSLIST_TIME, 0)
+	FROM performance_schema.threads
+	WHERE PROCESSLIST_COMMAND IN ('Binlog Dump', 'Binlog Dump GTID')`
+
+	// Semi-sync source status variables: rpl_semi_sync_source_* as of 8.0.26
+	// (semisync_source plugin), else rpl_semi_sync_master_* (semisync_master
+	// plugin). Zero rows if neither plugin is loaded.
+	SEMI_SYNC_QUERY = `SELECT VARIABLE_NAME, VARIABLE_VALUE FROM performance_schema.global_status
+	WHERE VARIABLE_NAME LIKE 'Rpl\_semi\_sync\_source\_%' OR VARIABLE_NAME LIKE 'Rpl\_semi\_sync\_master\_%'`
+
+	GTID_EXECUTED_QUERY = "SELECT @@global.gtid_executed"
+)
+
+// semiSync maps semi-sync metric names to metric types. The metric name is
+// the status variable name without the rpl_semi_sync_source_ (or _master_)
+// prefix, prefixed with semi_sync_.
+var semiSync = map[string]byte{
+	"semi_sync_status":            blip.BOOL,
+	"semi_sync_clients":           blip.GAUGE,
+	"semi_sync_yes_tx":            blip.CUMULATIVE_COUNTER,
+	"semi_sync_no_tx":             blip.CUMULATIVE_COUNTER,
+	"semi_sync_no_times":          blip.CUMULATIVE_COUNTER,
+	"semi_sync_tx_avg_wait_time":  blip.GAUGE,
+	"semi_sync_tx_wait_time":      blip.CUMULATIVE_COUNTER,
+	"semi_sync_tx_waits":          blip.CUMULATIVE_COUNTER,
+	"semi_sync_net_avg_wait_time": blip.GAUGE,
+	"semi_sync_wait_sessions":     blip.GAUGE,
+}
+
+type sourceMetrics struct {
+	dump     bool // replicas or binlog_dump_age
+	semiSync bool // any semi_sync_*
+	gtid     bool // gtid_executed
+	metrics  map[string]bool
+}
+
+// Source collects replication metrics from the source side: connected replicas
+// (binlog dump threads), semi-sync replication, and the GTID executed set.
+// Every metric is valid on a replica, too: a replica without replicas has zero
+// binlog dump threads, and semi-sync metrics are reported only if the semi-sync
+// source plugin is loaded. So the domain is safe in plans used for sources and
+// replicas.
+type Source struct {
+	db      *sql.DB
+	atLevel map[string]sourceMetrics
+}
+
+var _ blip.Collector = &Source{}
+
+func NewSource(db *sql.DB) *Source {
+	return &Source{
+		db:      db,
+		atLevel: map[string]sourceMetrics{},
+	}
+}
+
+func (c *Source) Domain() string {
+	return DOMAIN
+}
+
+func (c *Source) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Replication source status: replicas, semi-sync, GTID executed",
+		Groups: []blip.CollectorKeyValue{
+			{Key: "host", Value: "the replica host (binlog_dump_age only)"},
+			{Key: "processlist_id", Value: "the binlog dump thread processlist ID (binlog_dump_age only)"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "replicas",
+				Type: blip.GAUGE,
+				Desc: "Number of connected replicas (binlog dump threads)",
+			},
+			{
+				Name: "binlog_dump_age",
+				Type: blip.GAUGE,
+				Desc: "Seconds binlog dump thread has been in its current state (PROCESSLIST_TIME)",
+			},
+			{
+				Name: "semi_sync_status",
+				Type: blip.BOOL,
+				Desc: "1=semi-sync replication is operational, 0=not operational (asynchronous)",
+			},
+			{
+				Name: "semi_sync_clients",
+				Type: blip.GAUGE,
+				Desc: "Number of semi-sync replicas",
+			},
+			{
+				Name: "semi_sync_yes_tx",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Number of commits acknowledged by a replica",
+			},
+			{
+				Name: "semi_sync_no_tx",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Number of commits not acknowledged by a replica",
+			},
+			{
+				Name: "semi_sync_no_times",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Number of times semi-sync was turned off",
+			},
+			{
+				Name: "semi_sync_tx_avg_wait_time",
+				Type: blip.GAUGE,
+				Desc: "Average time waiting for replica acknowledgement (microseconds)",
+			},
+			{
+				Name: "semi_sync_tx_wait_time",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Total time waiting for replica acknowledgement (microseconds)",
+			},
+			{
+				Name: "semi_sync_tx_waits",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Number of times waited for replica acknowledgement",
+			},
+			{
+				Name: "semi_sync_net_avg_wait_time",
+				Type: blip.GAUGE,
+				Desc: "Average time waiting for replica reply (microseconds; deprecated, always 0 in MySQL 8.x)",
+			},
+			{
+				Name: "semi_sync_wait_sessions",
+				Type: blip.GAUGE,
+				Desc: "Number of sessions currently waiting for replica acknowledgement",
+			},
+			{
+				Name: "gtid_executed",
+				Type: blip.GAUGE,
+				Desc: "Number of transactions in @@global.gtid_executed (0 if GTIDs not enabled)",
+			},
+		},
+	}
+}
+
+func (c *Source) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+
+		m := sourceMetrics{
+			metrics: map[string]bool{},
+		}
+		for _, name := range dom.Metrics {
+			switch {
+			case name == "replicas" || name == "binlog_dump_age":
+				m.dump = true
+			case name == "gtid_executed":
+				m.gtid = true
+			case semiSync[name] != blip.UNKNOWN:
+				m.semiSync = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+			m.metrics[name] = true
+		}
+
+		c.atLevel[level.Name] = m
+	}
+	return nil, nil
+}
+
+func (c *Source) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	m, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	metrics := []blip.MetricValue{}
+
+	if m.dump {
+		dump, err := c.collectDump(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, dump...)
+	}
+
+	if m.semiSync {
+		rows, err := c.db.QueryContext(ctx, SEMI_SYNC_QUERY)
+		if err != nil {
+			return nil, fmt.Errorf("%s failed: %s", SEMI_SYNC_QUERY, err)
+		}
+		defer rows.Close()
+		vars := map[string]string{}
+		var name, val string
+		for rows.Next() {
+			if err = rows.Scan(&name, &val); err != nil {
+				return nil, err
+			}
+			vars[name] = val
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, SemiSyncMetrics(vars, m.metrics)...)
+	}
+
+	if m.gtid {
+		var set sql.NullString
+		if err := c.db.QueryRowContext(ctx, GTID_EXECUTED_QUERY).Scan(&set); err != nil {
+			return nil, fmt.Errorf("%s failed: %s", GTID_EXECUTED_QUERY, err)
+		}
+		n, err := repl.GtidSetSize(set.String)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "gtid_executed",
+			Type:  blip.GAUGE,
+			Value: n,
+		})
+	}
+
+	return metrics, nil
+}
+
+func (c *Source) collectDump(ctx context.Context, m sourceMetrics) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, BINLOG_DUMP_QUERY)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %s", BINLOG_DUMP_QUERY, err)
+	}
+	defer rows.Close()
+
+	var (
+		metrics  []blip.MetricValue
+		replicas float64
+		id       string
+		host     string
+		age      float64
+	)
+	for rows.Next() {
+		if err = rows.Scan(&id, &host, &age); err != nil {
+			return nil, err
+		}
+		replicas++
+		if !m.metrics["binlog_dump_age"] {
+			continue
+		}
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "binlog_dump_age",
+			Type:  blip.GAUGE,
+			Value: age,
+			Group: map[string]string{"host": host, "processlist_id": id},
+		})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if m.metrics["replicas"] {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "replicas",
+			Type:  blip.GAUGE,
+			Value: replicas, // 0 if not a source
+		})
+	}
+	return metrics, nil
+}
+
+// SemiSyncMetrics returns the semi-sync metrics from the status variables,
+// keyed on variable name (any case). Only metrics in the given map are returned,
+// and only if the variable exists: if semi-sync is not loaded, there are no
+// variables and no metrics.
+func SemiSyncMetrics(vars map[string]string, metrics map[string]bool) []blip.MetricValue {
+	values := []blip.MetricValue{}
+	for varName, val := range vars {
+		name := strings.ToLower(varName)
+		if s, ok := strings.CutPrefix(name, "rpl_semi_sync_source_"); ok {
+			name = "semi_sync_" + s
+		} else if s, ok := strings.CutPrefix(name, "rpl_semi_sync_master_"); ok {
+			name = "semi_sync_" + s
+		} else {
+			continue
+		}
+		if !metrics[name] {
+			continue
+		}
+		v, ok := sqlutil.Float64(val)
+		if !ok {
+			blip.Debug("%s: cannot convert %s value to float: %s", DOMAIN, varName, val)
+			continue
+		}
+		values = append(values, blip.MetricValue{
+			Name:  name,
+			Type:  semiSync[name],
+			Value: v,
+		})
+	}
+	return values
+}
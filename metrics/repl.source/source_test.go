@@ -0,0 +1,52 @@
+// Copyright 2024 Block, Inc.
+
+package replsource_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+	replsource "github.com/cashapp/blip/metrics/repl.source"
+)
+
+func TestSemiSyncMetrics(t *testing.T) {
+	metrics := map[string]bool{
+		"semi_sync_status":           true,
+		"semi_sync_clients":          true,
+		"semi_sync_no_tx":            true,
+		"semi_sync_tx_avg_wait_time": true,
+	}
+
+	// Old (master) and new (source) variable names return the same metrics;
+	// unknown values and metrics not collected are ignored
+	for _, prefix := range []string{"Rpl_semi_sync_master_", "Rpl_semi_sync_source_"} {
+		vars := map[string]string{
+			prefix + "status":            "ON",
+			prefix + "clients":           "2",
+			prefix + "yes_tx":            "100", // not collected
+			prefix + "no_tx":             "3",
+			prefix + "tx_avg_wait_time":  "250",
+			prefix + "timefunc_failures": "0", // not a metric
+		}
+		got := replsource.SemiSyncMetrics(vars, metrics)
+		sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+		expect := []blip.MetricValue{
+			{Name: "semi_sync_clients", Type: blip.GAUGE, Value: 2},
+			{Name: "semi_sync_no_tx", Type: blip.CUMULATIVE_COUNTER, Value: 3},
+			{Name: "semi_sync_status", Type: blip.BOOL, Value: 1},
+			{Name: "semi_sync_tx_avg_wait_time", Type: blip.GAUGE, Value: 250},
+		}
+		if diff := deep.Equal(got, expect); diff != nil {
+			t.Errorf("%s: %v", prefix, diff)
+		}
+	}
+
+	// Semi-sync not loaded: no variables, no metrics
+	got := replsource.SemiSyncMetrics(map[string]string{}, metrics)
+	if len(got) != 0 {
+		t.Errorf("got %d metrics, expected 0: %v", len(got), got)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Block, Inc.
+
+package repl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type interval struct {
+	start int64
+	end   int64
+}
+
+// parseGtidSet parses a GTID set like "uuid:1-5:7,uuid2:1-10" into intervals
+// keyed on source UUID. Tagged GTIDs (MySQL 8.3 and newer) like "uuid:tag:1-5"
+// are keyed on "uuid:tag".
+func parseGtidSet(set string) (map[string][]interval, error) {
+	gtids := map[string][]interval{}
+	set = strings.Join(strings.Fields(set), "") // remove newlines added by MySQL
+	if set == "" {
+		return gtids, nil
+	}
+	for _, s := range strings.Split(set, ",") {
+		parts := strings.Split(s, ":")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("invalid GTID set: %s: no intervals: %s", set, s)
+		}
+		key := parts[0]
+		for _, p := range parts[1:] {
+			if p == "" || p[0] < '0' || p[0] > '9' {
+				key = parts[0] + ":" + p // tag
+				continue
+			}
+			var i interval
+			var err error
+			startEnd := strings.SplitN(p, "-", 2)
+			if i.start, err = strconv.ParseInt(startEnd[0], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid GTID set: %s: %s", set, err)
+			}
+			i.end = i.start
+			if len(startEnd) == 2 {
+				if i.end, err = strconv.ParseInt(startEnd[1], 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid GTID set: %s: %s", set, err)
+				}
+			}
+			gtids[key] = append(gtids[key], i)
+		}
+	}
+	return gtids, nil
+}
+
+// GtidGap returns the number of transactions in GTID set retrieved that are not
+// in GTID set executed: transactions received by the replica but not yet applied.
+// The sets are the Retrieved_Gtid_Set and Executed_Gtid_Set values from
+// SHOW REPLICA STATUS.
+func GtidGap(retrieved, executed string) (float64, error) {
+	r, err := parseGtidSet(retrieved)
+	if err != nil {
+		return 0, err
+	}
+	e, err := parseGtidSet(executed)
+	if err != nil {
+		return 0, err
+	}
+	var gap int64
+	for key, intervals := range r {
+		for _, ri := range intervals {
+			n := ri.end - ri.start + 1
+			// MySQL prints normalized sets: intervals do not overlap
+			for _, ei := range e[key] {
+				start := max(ri.start, ei.start)
+				end := min(ri.end, ei.end)
+				if end >= start {
+					n -= end - start + 1
+				}
+			}
+			gap += n
+		}
+	}
+	return float64(gap), nil
+}
+
+// GtidSetSize returns the number of transactions in the GTID set, like
+// @@global.gtid_executed.
+func GtidSetSize(set string) (float64, error) {
+	gtids, err := parseGtidSet(set)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, intervals := range gtids {
+		for _, i := range intervals {
+			n += i.end - i.start + 1
+		}
+	}
+	return float64(n), nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Block, Inc.
+
+package repl_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip/metrics/repl"
+)
+
+func TestGtidGap(t *testing.T) {
+	tests := []struct {
+		retrieved string
+		executed  string
+		gap       float64
+	}{
+		// Not GTID replication or nothing retrieved
+		{"", "", 0},
+		{"", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100", 0},
+
+		// Caught up
+		{
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:50-100",
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100",
+			0,
+		},
+
+		// 10 behind
+		{
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:50-100",
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-90",
+			10,
+		},
+
+		// Multiple sources, intervals, and newlines like MySQL prints
+		{
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10:20-30,\n4e11fa47-71ca-11e1-9e33-c80aa9429562:1-5",
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10:20-25,\n4e11fa47-71ca-11e1-9e33-c80aa9429562:1-3",
+			7, // 26-30 and 4-5
+		},
+
+		// Single transaction and no executed for the source
+		{
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:7",
+			"4e11fa47-71ca-11e1-9e33-c80aa9429562:1-100",
+			1,
+		},
+
+		// Tagged GTIDs (MySQL 8.3)
+		{
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10:etl:1-5",
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10:etl:1-2",
+			3,
+		},
+	}
+	for _, test := range tests {
+		gap, err := repl.GtidGap(test.retrieved, test.executed)
+		if err != nil {
+			t.Errorf("GtidGap(%q, %q): error: %s", test.retrieved, test.executed, err)
+			continue
+		}
+		if gap != test.gap {
+			t.Errorf("GtidGap(%q, %q) = %f, expected %f", test.retrieved, test.executed, gap, test.gap)
+		}
+	}
+
+	if _, err := repl.GtidGap("3e11fa47-71ca-11e1-9e33-c80aa9429562", ""); err == nil {
+		t.Error("no error for GTID set without intervals, expected an error")
+	}
+}
+
+func TestGtidSetSize(t *testing.T) {
+	tests := []struct {
+		set  string
+		size float64
+	}{
+		{"", 0},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:7", 1},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100", 100},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10:20-30,\n4e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", 26},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10:etl:1-5", 15},
+	}
+	for _, test := range tests {
+		size, err := repl.GtidSetSize(test.set)
+		if err != nil {
+			t.Errorf("GtidSetSize(%q): error: %s", test.set, err)
+			continue
+		}
+		if size != test.size {
+			t.Errorf("GtidSetSize(%q) = %f, expected %f", test.set, size, test.size)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	myerr "github.com/go-mysql/errors"
 
@@ -22,10 +23,12 @@ const (
 	ERR_NO_ACCESS = "access-denied"
 
 	OPT_REPORT_NOT_A_REPLICA = "report-not-a-replica"
+	OPT_CHANNELS             = "channels"
 )
 
 type replMetrics struct {
-	chedkRunning bool
+	metrics  map[string]bool
+	channels map[string]bool // nil = all channels
 }
 
 type Repl struct {
@@ -72,6 +75,16 @@ func (c *Repl) Help() blip.CollectorHelp {
 					"no":  "Disabled: drop repl.running if not a replica",
 				},
 			},
+			OPT_CHANNELS: {
+				Name: OPT_CHANNELS,
+				Desc: "Comma-separated list of channel names to report (default: all channels; empty name is the default channel)",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "channel_name", Value: "the replication channel name (empty string for the default channel)"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "source", Value: "the source host (running metric only)"},
 		},
 		Metrics: []blip.CollectorMetric{
 			{
@@ -79,6 +92,31 @@ func (c *Repl) Help() blip.CollectorHelp {
 				Type: blip.GAUGE,
 				Desc: "1=running (no error), 0=not running, -1=not a replica",
 			},
+			{
+				Name: "io_running",
+				Type: blip.BOOL,
+				Desc: "1=IO (receiver) thread running, 0=not running or connecting",
+			},
+			{
+				Name: "sql_running",
+				Type: blip.BOOL,
+				Desc: "1=SQL (applier) thread running, 0=not running",
+			},
+			{
+				Name: "relay_log_space",
+				Type: blip.GAUGE,
+				Desc: "Total size of all relay log files in bytes",
+			},
+			{
+				Name: "seconds_behind_source",
+				Type: blip.GAUGE,
+				Desc: "Seconds_Behind_Source (not reported if NULL)",
+			},
+			{
+				Name: "gtid_gap",
+				Type: blip.GAUGE,
+				Desc: "Number of transactions retrieved but not executed (Retrieved_Gtid_Set minus Executed_Gtid_Set)",
+			},
 		},
 		Errors: map[string]blip.CollectorHelpError{
 			ERR_NO_ACCESS: {
@@ -104,15 +142,23 @@ LEVEL:
 			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
 		}
 
-		m := replMetrics{}
+		m := replMetrics{
+			metrics: map[string]bool{},
+		}
 		for i := range dom.Metrics {
 			switch dom.Metrics[i] {
-			case "running":
-				m.chedkRunning = true
+			case "running", "io_running", "sql_running", "relay_log_space", "seconds_behind_source", "gtid_gap":
+				m.metrics[dom.Metrics[i]] = true
 			default:
 				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", dom.Metrics[i])
 			}
 		}
+		if channels, ok := dom.Options[OPT_CHANNELS]; ok {
+			m.channels = map[string]bool{}
+			for _, ch := range strings.Split(channels, ",") {
+				m.channels[strings.TrimSpace(ch)] = true
+			}
+		}
 		c.atLevel[level.Name] = m
 		c.dropNotAReplica[level.Name] = !blip.Bool(dom.Options[OPT_REPORT_NOT_A_REPLICA])
 
@@ -154,56 +200,133 @@ func (c *Repl) Collect(ctx context.Context, levelName string) ([]blip.MetricValu
 		return nil, nil
 	}
 
-	// Return SHOW SLAVE|REPLICA STATUS as map[string]string, which can be nil
-	// if MySQL is not a replica
-	replStatus, err := sqlutil.RowToMap(ctx, c.db, c.statusQuery)
+	// Return SHOW SLAVE|REPLICA STATUS as []map[string]string, one map per
+	// channel, which can be nil if MySQL is not a replica
+	replStatus, err := sqlutil.RowsToMaps(ctx, c.db, c.statusQuery)
 	if err != nil {
 		return c.collectError(err)
 	}
 
+	// No SHOW SLAVE|REPLICA STATUS output = not a replica
+	if len(replStatus) == 0 {
+		if !rm.metrics["running"] || c.dropNotAReplica[levelName] {
+			return nil, nil
+		}
+		return []blip.MetricValue{{
+			Name:  "running",
+			Type:  blip.GAUGE,
+			Value: float64(NOT_A_REPLICA),
+			Group: map[string]string{"channel_name": ""},
+			Meta:  map[string]string{"source": ""},
+		}}, nil
+	}
+
+	metrics := []blip.MetricValue{}
+	for _, status := range replStatus {
+		channel := status["Channel_Name"] // empty for default channel
+		if rm.channels != nil && !rm.channels[channel] {
+			continue
+		}
+		metrics = append(metrics, c.channelMetrics(rm, channel, status)...)
+	}
+
+	return metrics, nil
+}
+
+// channelMetrics returns the metrics for one replication channel: one row
+// of SHOW SLAVE|REPLICA STATUS. Running values are literal, not passed through
+// sqlutil.Float64, so we look for "Yes" not 1, which works in this specific case.
+func (c *Repl) channelMetrics(rm replMetrics, channel string, status map[string]string) []blip.MetricValue {
+	group := map[string]string{"channel_name": channel}
+
+	// New terms (source, replica) as of 8.0.22
+	var ioRunning, sqlRunning bool
+	var source, behind string
+	if c.newTerms {
+		ioRunning = status["Replica_IO_Running"] == "Yes"
+		sqlRunning = status["Replica_SQL_Running"] == "Yes"
+		source = status["Source_Host"]
+		behind = status["Seconds_Behind_Source"]
+	} else {
+		ioRunning = status["Slave_IO_Running"] == "Yes"
+		sqlRunning = status["Slave_SQL_Running"] == "Yes"
+		source = status["Master_Host"]
+		behind = status["Seconds_Behind_Master"]
+	}
+
 	metrics := []blip.MetricValue{}
 
-	// Report repl.running: 1=running, 0=not running, -1=not a replica
-	//
-	// NOTE: values are literal, not passed through sqlutil.Float64, so
-	//       we look for "Yes" not 1, which works in this specific case.
-	if rm.chedkRunning {
+	// Report repl.running: 1=running, 0=not running
+	if rm.metrics["running"] {
 		var running float64 // 0 = not running by default
-		if len(replStatus) == 0 {
-			// no SHOW SLAVE|REPLICA STATUS output = not a replica
-			running = float64(NOT_A_REPLICA)
-		} else if (replStatus["Slave_IO_Running"] == "Yes" || replStatus["Replica_IO_Running"] == "Yes") &&
-			(replStatus["Slave_SQL_Running"] == "Yes" || replStatus["Replica_SQL_Running"] == "Yes") && replStatus["Last_Errno"] == "0" {
+		if ioRunning && sqlRunning && status["Last_Errno"] == "0" {
 			// running if a replica and those ^ 3 conditions are true
 			running = 1
 		}
-
-		if running == NOT_A_REPLICA {
-			if c.dropNotAReplica[levelName] {
-				return nil, nil
-			}
-		}
-
-		m := blip.MetricValue{
+		metrics = append(metrics, blip.MetricValue{
 			Name:  "running",
 			Type:  blip.GAUGE,
 			Value: running,
-			Meta:  map[string]string{"source": ""},
+			Group: group,
+			Meta:  map[string]string{"source": source},
+		})
+	}
+
+	if rm.metrics["io_running"] {
+		m := blip.MetricValue{Name: "io_running", Type: blip.BOOL, Group: group}
+		if ioRunning {
+			m.Value = 1
 		}
-		// Make sure we have results.
-		if len(replStatus) != 0 {
-			if c.newTerms {
-				m.Meta["source"] = replStatus["Source_Host"]
-			} else {
-				m.Meta["source"] = replStatus["Master_Host"]
-			}
+		metrics = append(metrics, m)
+	}
+
+	if rm.metrics["sql_running"] {
+		m := blip.MetricValue{Name: "sql_running", Type: blip.BOOL, Group: group}
+		if sqlRunning {
+			m.Value = 1
 		}
 		metrics = append(metrics, m)
 	}
 
-	// @todo collect other repl status metrics
+	if rm.metrics["relay_log_space"] {
+		if v, ok := sqlutil.Float64(status["Relay_Log_Space"]); ok {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "relay_log_space",
+				Type:  blip.GAUGE,
+				Value: v,
+				Group: group,
+			})
+		}
+	}
+
+	// Seconds_Behind_Source is NULL (empty string) if the SQL thread is not
+	// running or the IO thread is not connected; that's not zero, so drop it
+	if rm.metrics["seconds_behind_source"] {
+		if v, ok := sqlutil.Float64(behind); ok {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "seconds_behind_source",
+				Type:  blip.GAUGE,
+				Value: v,
+				Group: group,
+			})
+		}
+	}
 
-	return metrics, nil
+	if rm.metrics["gtid_gap"] {
+		gap, err := GtidGap(status["Retrieved_Gtid_Set"], status["Executed_Gtid_Set"])
+		if err != nil {
+			blip.Debug("channel '%s': %s", channel, err)
+		} else {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "gtid_gap",
+				Type:  blip.GAUGE,
+				Value: gap,
+				Group: group,
+			})
+		}
+	}
+
+	return metrics
 }
 
 func (c *Repl) collectError(err error) ([]blip.MetricValue, error) {
@@ -236,6 +359,7 @@ func (c *Repl) collectError(err error) ([]blip.MetricValue, error) {
 			Name:  "running",
 			Type:  blip.GAUGE,
 			Value: 0,
+			Group: map[string]string{"channel_name": ""},
 			Meta:  map[string]string{"source": ""},
 		}}
 	}
@@ -0,0 +1,437 @@
+// Copyright 2024 Block, Inc.
+
+package rocksdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	myerr "github.com/go-mysql/errors"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/errors"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	DOMAIN = "rocksdb"
+
+	ERR_NO_ROCKSDB = "rocksdb-not-enabled"
+)
+
+const (
+	DBSTATS_QUERY    = "SELECT STAT_TYPE, VALUE FROM information_schema.ROCKSDB_DBSTATS"
+	CFSTATS_QUERY    = "SELECT CF_NAME, STAT_TYPE, VALUE FROM information_schema.ROCKSDB_CFSTATS"
+	CF_OPTIONS_QUERY = "SELECT CF_NAME, OPTION_TYPE, VALUE FROM information_schema.ROCKSDB_CF_OPTIONS"
+	STATUS_QUERY     = "SHOW GLOBAL STATUS LIKE 'rocksdb\\_%'"
+
+	// SST_QUERY sums SST block sizes per column family. ROCKSDB_SST_PROPS has
+	// only the column family ID, so the name is from ROCKSDB_DDL (or the ID if
+	// no index uses the column family).
+	SST_QUERY = `SELECT COALESCE(cf.CF, CAST(p.COLUMN_FAMILY AS CHAR)), COUNT(*), SUM(p.DATA_BLOCK_SIZE + p.INDEX_BLOCK_SIZE + p.FILTER_BLOCK_SIZE)
+	FROM information_schema.ROCKSDB_SST_PROPS p
+	LEFT JOIN (SELECT DISTINCT COLUMN_FAMILY, CF FROM information_schema.ROCKSDB_DDL) cf ON cf.COLUMN_FAMILY = p.COLUMN_FAMILY
+	GROUP BY p.COLUMN_FAMILY, cf.CF`
+)
+
+// Metric name prefixes that determine the source of a metric, except derived
+// metrics (sstMetrics and hitRatios).
+const (
+	PREFIX_DB        = "db_"        // ROCKSDB_DBSTATS
+	PREFIX_CF        = "cf_"        // ROCKSDB_CFSTATS
+	PREFIX_CF_OPTION = "cf_option_" // ROCKSDB_CF_OPTIONS
+	PREFIX_STATUS    = "rocksdb_"   // SHOW GLOBAL STATUS
+)
+
+// Derived metrics from SST_QUERY.
+var sstMetrics = map[string]bool{
+	"cf_sst_files": true,
+	"cf_sst_bytes": true,
+}
+
+// Derived block cache hit ratios => status variable prefix of the hit and miss
+// counters, like rocksdb_block_cache_data_hit and rocksdb_block_cache_data_miss.
+var hitRatios = map[string]string{
+	"block_cache_hit_ratio":        "rocksdb_block_cache_",
+	"block_cache_data_hit_ratio":   "rocksdb_block_cache_data_",
+	"block_cache_index_hit_ratio":  "rocksdb_block_cache_index_",
+	"block_cache_filter_hit_ratio": "rocksdb_block_cache_filter_",
+}
+
+// Status variables that are gauges; all others are cumulative counters.
+var statusGauge = map[string]bool{
+	"rocksdb_memtable_total":     true,
+	"rocksdb_memtable_unflushed": true,
+}
+
+type rocksdbLevel struct {
+	db        map[string]bool // ROCKSDB_DBSTATS metrics
+	cf        map[string]bool // ROCKSDB_CFSTATS metrics
+	cfOptions map[string]bool // ROCKSDB_CF_OPTIONS metrics
+	status    map[string]bool // status metrics
+	sst       map[string]bool // sstMetrics
+	ratios    []string        // hitRatios
+	last      map[string]float64
+}
+
+// RocksDB collects metrics for the rocksdb domain: MyRocks storage engine
+// metrics from RocksDB information_schema tables and rocksdb_* status variables.
+type RocksDB struct {
+	db *sql.DB
+	// --
+	atLevel   map[string]*rocksdbLevel
+	errPolicy map[string]*errors.Policy
+	stop      bool
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &RocksDB{}
+
+// NewRocksDB makes a new RocksDB collector.
+func NewRocksDB(db *sql.DB) *RocksDB {
+	return &RocksDB{
+		db:        db,
+		atLevel:   map[string]*rocksdbLevel{},
+		errPolicy: map[string]*errors.Policy{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *RocksDB) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *RocksDB) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "MyRocks (RocksDB storage engine) metrics",
+		Groups: []blip.CollectorKeyValue{
+			{Key: "cf", Value: "the column family name (" + PREFIX_CF + "* metrics only)"},
+		},
+		Errors: map[string]blip.CollectorHelpError{
+			ERR_NO_ROCKSDB: {
+				Name:    ERR_NO_ROCKSDB,
+				Handles: "MySQL error 1109: unknown RocksDB information_schema table (MyRocks not installed)",
+				Default: errors.NewPolicy("").String(),
+			},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: PREFIX_DB + "*",
+				Type: blip.GAUGE,
+				Desc: "ROCKSDB_DBSTATS STAT_TYPE in lowercase, like db_block_cache_usage (db_background_errors is a cumulative counter)",
+			},
+			{
+				Name: PREFIX_CF + "*",
+				Type: blip.GAUGE,
+				Desc: "ROCKSDB_CFSTATS STAT_TYPE in lowercase with prefix " + PREFIX_CF + ", like cf_compaction_pending and cf_cur_size_all_mem_tables",
+			},
+			{
+				Name: PREFIX_CF_OPTION + "*",
+				Type: blip.GAUGE,
+				Desc: "ROCKSDB_CF_OPTIONS OPTION_TYPE in lowercase with prefix " + PREFIX_CF_OPTION + ", like cf_option_write_buffer_size (numeric options only)",
+			},
+			{
+				Name: PREFIX_STATUS + "*",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "rocksdb_* global status variable, like rocksdb_stall_total_stops (rocksdb_memtable_total and rocksdb_memtable_unflushed are gauges)",
+			},
+			{
+				Name: "cf_sst_files",
+				Type: blip.GAUGE,
+				Desc: "Number of SST files in the column family",
+			},
+			{
+				Name: "cf_sst_bytes",
+				Type: blip.GAUGE,
+				Desc: "Size of SST files in the column family (sum of data, index, and filter blocks)",
+			},
+			{
+				Name: "block_cache_hit_ratio",
+				Type: blip.GAUGE,
+				Desc: "Block cache hit ratio during the interval",
+			},
+			{
+				Name: "block_cache_data_hit_ratio",
+				Type: blip.GAUGE,
+				Desc: "Block cache hit ratio for data blocks during the interval",
+			},
+			{
+				Name: "block_cache_index_hit_ratio",
+				Type: blip.GAUGE,
+				Desc: "Block cache hit ratio for index blocks during the interval",
+			},
+			{
+				Name: "block_cache_filter_hit_ratio",
+				Type: blip.GAUGE,
+				Desc: "Block cache hit ratio for filter blocks during the interval",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *RocksDB) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected at this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+		l := &rocksdbLevel{
+			db:        map[string]bool{},
+			cf:        map[string]bool{},
+			cfOptions: map[string]bool{},
+			status:    map[string]bool{},
+			sst:       map[string]bool{},
+		}
+		for _, name := range dom.Metrics {
+			name = strings.ToLower(name)
+			switch {
+			case sstMetrics[name]:
+				l.sst[name] = true
+			case hitRatios[name] != "":
+				l.ratios = append(l.ratios, name)
+			case strings.HasPrefix(name, PREFIX_CF_OPTION):
+				l.cfOptions[strings.TrimPrefix(name, PREFIX_CF_OPTION)] = true
+			case strings.HasPrefix(name, PREFIX_CF):
+				l.cf[strings.TrimPrefix(name, PREFIX_CF)] = true
+			case strings.HasPrefix(name, PREFIX_DB):
+				l.db[name] = true
+			case strings.HasPrefix(name, PREFIX_STATUS):
+				l.status[name] = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
+		}
+
+		c.errPolicy[ERR_NO_ROCKSDB] = errors.NewPolicy(dom.Errors[ERR_NO_ROCKSDB])
+		blip.Debug("error policy: %s=%s", ERR_NO_ROCKSDB, c.errPolicy[ERR_NO_ROCKSDB])
+
+		c.atLevel[level.Name] = l
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *RocksDB) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	l, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	if c.stop {
+		blip.Debug("stopped by previous error")
+		return nil, nil
+	}
+
+	var metrics []blip.MetricValue
+
+	if len(l.db) > 0 {
+		vals, err := c.stats(ctx, DBSTATS_QUERY, false)
+		if err != nil {
+			return c.collectError(err)
+		}
+		for _, v := range vals {
+			if !l.db[v.name] {
+				continue
+			}
+			m := blip.MetricValue{Name: v.name, Value: v.value, Type: blip.GAUGE}
+			if v.name == "db_background_errors" {
+				m.Type = blip.CUMULATIVE_COUNTER
+			}
+			metrics = append(metrics, m)
+		}
+	}
+
+	if len(l.cf) > 0 {
+		vals, err := c.stats(ctx, CFSTATS_QUERY, true)
+		if err != nil {
+			return c.collectError(err)
+		}
+		for _, v := range vals {
+			if !l.cf[v.name] {
+				continue
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  PREFIX_CF + v.name,
+				Value: v.value,
+				Type:  blip.GAUGE,
+				Group: map[string]string{"cf": v.cf},
+			})
+		}
+	}
+
+	if len(l.cfOptions) > 0 {
+		vals, err := c.stats(ctx, CF_OPTIONS_QUERY, true)
+		if err != nil {
+			return c.collectError(err)
+		}
+		for _, v := range vals {
+			if !l.cfOptions[v.name] {
+				continue
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  PREFIX_CF_OPTION + v.name,
+				Value: v.value,
+				Type:  blip.GAUGE,
+				Group: map[string]string{"cf": v.cf},
+			})
+		}
+	}
+
+	if len(l.sst) > 0 {
+		sst, err := c.sst(ctx, l.sst)
+		if err != nil {
+			return c.collectError(err)
+		}
+		metrics = append(metrics, sst...)
+	}
+
+	if len(l.status) > 0 || len(l.ratios) > 0 {
+		vals, err := c.stats(ctx, STATUS_QUERY, false)
+		if err != nil {
+			return nil, err
+		}
+		cur := make(map[string]float64, len(vals))
+		for _, v := range vals {
+			cur[v.name] = v.value
+			if !l.status[v.name] {
+				continue
+			}
+			m := blip.MetricValue{Name: v.name, Value: v.value, Type: blip.CUMULATIVE_COUNTER}
+			if statusGauge[v.name] {
+				m.Type = blip.GAUGE
+			}
+			metrics = append(metrics, m)
+		}
+		for _, name := range l.ratios {
+			if r, ok := HitRatio(l.last, cur, hitRatios[name]); ok {
+				metrics = append(metrics, blip.MetricValue{Name: name, Value: r, Type: blip.GAUGE})
+			}
+		}
+		l.last = cur
+	}
+
+	return metrics, nil
+}
+
+type stat struct {
+	cf    string
+	name  string
+	value float64
+}
+
+// stats returns the numeric values of a query that returns name and value
+// columns, or cf, name, and value columns if cf is true. Names are lowercase.
+func (c *RocksDB) stats(ctx context.Context, query string, cf bool) ([]stat, error) {
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		stats []stat
+		s     stat
+		val   string
+		ok    bool
+	)
+	for rows.Next() {
+		if cf {
+			err = rows.Scan(&s.cf, &s.name, &val)
+		} else {
+			err = rows.Scan(&s.name, &val)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.value, ok = sqlutil.Float64(val)
+		if !ok {
+			continue // non-numeric CF option like compression type
+		}
+		s.name = strings.ToLower(s.name)
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func (c *RocksDB) sst(ctx context.Context, metrics map[string]bool) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, SST_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		sst   []blip.MetricValue
+		cf    string
+		files float64
+		bytes float64
+	)
+	for rows.Next() {
+		if err = rows.Scan(&cf, &files, &bytes); err != nil {
+			return nil, err
+		}
+		group := map[string]string{"cf": cf}
+		if metrics["cf_sst_files"] {
+			sst = append(sst, blip.MetricValue{Name: "cf_sst_files", Value: files, Type: blip.GAUGE, Group: group})
+		}
+		if metrics["cf_sst_bytes"] {
+			sst = append(sst, blip.MetricValue{Name: "cf_sst_bytes", Value: bytes, Type: blip.GAUGE, Group: group})
+		}
+	}
+	return sst, rows.Err()
+}
+
+// HitRatio returns the hit ratio between the previous and current status values
+// of the hit and miss counters with the given prefix, like "rocksdb_block_cache_"
+// for rocksdb_block_cache_hit and rocksdb_block_cache_miss. It returns false if
+// there are no previous values, the counters don't exist, or there were no hits
+// or misses.
+func HitRatio(prev, cur map[string]float64, prefix string) (float64, bool) {
+	hit, miss := prefix+"hit", prefix+"miss"
+	prevHit, ok1 := prev[hit]
+	prevMiss, ok2 := prev[miss]
+	curHit, ok3 := cur[hit]
+	curMiss, ok4 := cur[miss]
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return 0, false
+	}
+	dHit, dMiss := curHit-prevHit, curMiss-prevMiss
+	if dHit < 0 || dMiss < 0 || dHit+dMiss == 0 {
+		return 0, false // counter reset (MySQL restart) or no activity
+	}
+	return dHit / (dHit + dMiss), true
+}
+
+func (c *RocksDB) collectError(err error) ([]blip.MetricValue, error) {
+	var ep *errors.Policy
+	switch myerr.MySQLErrorCode(err) {
+	case 1109:
+		ep = c.errPolicy[ERR_NO_ROCKSDB]
+	default:
+		return nil, err
+	}
+
+	// Stop trying to collect if error policy retry="stop"
+	if ep.Retry == errors.POLICY_RETRY_NO {
+		c.stop = true
+	}
+
+	// There's no metric to zero because the metrics are dynamic (names come
+	// from RocksDB), so the metric policy is always drop
+	if ep.ReportError() {
+		return nil, err
+	}
+	blip.Debug("error policy=ignore: %s", err)
+	return nil, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 Block, Inc.
+
+package rocksdb_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip/metrics/rocksdb"
+)
+
+func TestHitRatio(t *testing.T) {
+	prefix := "rocksdb_block_cache_data_"
+
+	// First collection: no previous values
+	cur := map[string]float64{
+		"rocksdb_block_cache_data_hit":  900,
+		"rocksdb_block_cache_data_miss": 100,
+	}
+	if _, ok := rocksdb.HitRatio(nil, cur, prefix); ok {
+		t.Error("got hit ratio without previous values, expected none")
+	}
+
+	// 300 hits, 100 misses during interval
+	prev := cur
+	cur = map[string]float64{
+		"rocksdb_block_cache_data_hit":  1200,
+		"rocksdb_block_cache_data_miss": 200,
+	}
+	got, ok := rocksdb.HitRatio(prev, cur, prefix)
+	if !ok {
+		t.Fatal("no hit ratio, expected one")
+	}
+	if got != 0.75 {
+		t.Errorf("got hit ratio %f, expected 0.75", got)
+	}
+
+	// No activity
+	if _, ok := rocksdb.HitRatio(cur, cur, prefix); ok {
+		t.Error("got hit ratio with no hits or misses, expected none")
+	}
+
+	// Counter reset
+	if _, ok := rocksdb.HitRatio(cur, prev, prefix); ok {
+		t.Error("got hit ratio after counter reset, expected none")
+	}
+
+	// Counters don't exist
+	if _, ok := rocksdb.HitRatio(prev, cur, "rocksdb_block_cache_index_"); ok {
+		t.Error("got hit ratio for counters that don't exist, expected none")
+	}
+}
@@ -49,18 +49,7 @@ func (c *Binlog) Help() blip.CollectorHelp {
 	return blip.CollectorHelp{
 		Domain:      DOMAIN,
 		Description: "Total size of all binary logs in bytes",
-		Errors: map[string]blip.CollectorHelpError{
-			ERR_NO_ACCESS: {
-				Name:    ERR_NO_ACCESS,
-				Handles: "MySQL error 1227: access denied on 'SHOW BINARY LOGS' (need REPLICATION CLIENT priv)",
-				Default: errors.NewPolicy("").String(), // defautl EAP
-			},
-			ERR_NO_BINLOGS: {
-				Name:    ERR_NO_BINLOGS,
-				Handles: "MySQL error 1381: binary logging not enabled",
-				Default: errors.NewPolicy("").String(), // defautl EAP
-			},
-		},
+		Errors:      ErrorsHelp(),
 		Metrics: []blip.CollectorMetric{
 			{
 				Name: "bytes",
@@ -149,13 +138,8 @@ func (c *Binlog) Collect(ctx context.Context, levelName string) ([]blip.MetricVa
 }
 
 func (c *Binlog) collectError(err error) ([]blip.MetricValue, error) {
-	var ep *errors.Policy
-	switch myerr.MySQLErrorCode(err) {
-	case 1381:
-		ep = c.errPolicy[ERR_NO_BINLOGS]
-	case 1227:
-		ep = c.errPolicy[ERR_NO_ACCESS]
-	default:
+	ep := ErrorPolicy(err, c.errPolicy)
+	if ep == nil {
 		return nil, err
 	}
 
@@ -185,3 +169,33 @@ func (c *Binlog) collectError(err error) ([]blip.MetricValue, error) {
 
 	return metrics, reportedErr
 }
+
+// ErrorsHelp returns the error policies for SHOW BINARY LOGS errors. The binlog
+// domain handles the same errors.
+func ErrorsHelp() map[string]blip.CollectorHelpError {
+	return map[string]blip.CollectorHelpError{
+		ERR_NO_ACCESS: {
+			Name:    ERR_NO_ACCESS,
+			Handles: "MySQL error 1227: access denied on 'SHOW BINARY LOGS' (need REPLICATION CLIENT priv)",
+			Default: errors.NewPolicy("").String(), // defautl EAP
+		},
+		ERR_NO_BINLOGS: {
+			Name:    ERR_NO_BINLOGS,
+			Handles: "MySQL error 1381: binary logging not enabled",
+			Default: errors.NewPolicy("").String(), // defautl EAP
+		},
+	}
+}
+
+// ErrorPolicy returns the policy for a SHOW BINARY LOGS error from the given
+// policies keyed on ERR_NO_ACCESS and ERR_NO_BINLOGS, or nil if the error is not
+// handled by a policy.
+func ErrorPolicy(err error, policy map[string]*errors.Policy) *errors.Policy {
+	switch myerr.MySQLErrorCode(err) {
+	case 1381:
+		return policy[ERR_NO_BINLOGS]
+	case 1227:
+		return policy[ERR_NO_ACCESS]
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 Block, Inc.
+
+package sizefile
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DOMAIN = "size.file"
+
+	OPT_EXCLUDE = "exclude"
+	OPT_INCLUDE = "include"
+)
+
+// File collects tablespace file sizes for domain size.file.
+// The source is information_schema.FILES.
+type File struct {
+	db *sql.DB
+	// --
+	query  map[string]string
+	params map[string][]interface{}
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &File{}
+
+// NewFile makes a new File collector,
+func NewFile(db *sql.DB) *File {
+	return &File{
+		db:     db,
+		query:  map[string]string{},
+		params: map[string][]interface{}{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (t *File) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (t *File) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "InnoDB undo and temporary tablespace file sizes",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_INCLUDE: {
+				Name: OPT_INCLUDE,
+				Desc: "Comma-separated list of tablespace name LIKE patterns to include, of any type (overrides option " + OPT_EXCLUDE + ")",
+			},
+			OPT_EXCLUDE: {
+				Name: OPT_EXCLUDE,
+				Desc: "Comma-separated list of undo or temporary tablespace name LIKE patterns to exclude (ignored if " + OPT_INCLUDE + " is set)",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "tablespace", Value: "the tablespace name, like innodb_undo_001 or innodb_temporary"},
+			{Key: "file", Value: "the tablespace file name, like ./undo_001 or ./ibtmp1"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "bytes",
+				Type: blip.GAUGE,
+				Desc: "File size",
+			},
+			{
+				Name: "free",
+				Type: blip.GAUGE,
+				Desc: "Free space in file",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (t *File) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		q, params, err := FileSizeQuery(dom.Options)
+		if err != nil {
+			return nil, err
+		}
+		t.query[level.Name] = q
+		t.params[level.Name] = params
+	}
+	return nil, nil
+}
+
+func (t *File) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	q, ok := t.query[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	rows, err := t.db.QueryContext(ctx, q, t.params[levelName]...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics    []blip.MetricValue
+		tablespace string
+		fileName   string
+		bytes      float64
+		free       float64
+	)
+
+	for rows.Next() {
+		if err = rows.Scan(&tablespace, &fileName, &bytes, &free); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics,
+			blip.MetricValue{
+				Name:  "bytes",
+				Type:  blip.GAUGE,
+				Group: map[string]string{"tablespace": tablespace, "file": fileName},
+				Value: bytes,
+			},
+			blip.MetricValue{
+				Name:  "free",
+				Type:  blip.GAUGE,
+				Group: map[string]string{"tablespace": tablespace, "file": fileName},
+				Value: free,
+			},
+		)
+	}
+
+	return metrics, rows.Err()
+}
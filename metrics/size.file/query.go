@@ -0,0 +1,49 @@
+// Copyright 2024 Block, Inc.
+
+package sizefile
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	base = "SELECT TABLESPACE_NAME, FILE_NAME, COALESCE(TOTAL_EXTENTS * EXTENT_SIZE, 0), COALESCE(DATA_FREE, 0)" +
+		" FROM information_schema.FILES WHERE ENGINE = 'InnoDB' AND "
+
+	// Undo tablespaces (innodb_undo_001, innodb_undo_002, and user-created undo
+	// tablespaces) and the global temporary tablespace (innodb_temporary, ibtmp1)
+	defaultWhere = "FILE_TYPE IN ('UNDO LOG', 'TEMPORARY')"
+)
+
+func FileSizeQuery(set map[string]string) (string, []interface{}, error) {
+	var where string
+	var params []interface{}
+	if include := set[OPT_INCLUDE]; include != "" {
+		where, params = setWhere(strings.Split(include, ","), true)
+	} else if exclude := set[OPT_EXCLUDE]; exclude != "" {
+		where, params = setWhere(strings.Split(exclude, ","), false)
+		where = defaultWhere + " AND " + where
+	} else {
+		where = defaultWhere
+		params = []interface{}{}
+	}
+	return base + where, params, nil
+}
+
+func setWhere(tablespaces []string, isInclude bool) (string, []interface{}) {
+	cond := make([]string, len(tablespaces))
+	params := make([]interface{}, len(tablespaces))
+	for i := range tablespaces {
+		params[i] = strings.TrimSpace(tablespaces[i])
+		if isInclude {
+			cond[i] = "TABLESPACE_NAME LIKE ?"
+		} else {
+			cond[i] = "TABLESPACE_NAME NOT LIKE ?"
+		}
+	}
+	if isInclude {
+		return fmt.Sprintf("(%s)", strings.Join(cond, " OR ")), params
+	}
+	return strings.Join(cond, " AND "), params
+}
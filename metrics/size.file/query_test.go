@@ -0,0 +1,58 @@
+// Copyright 2024 Block, Inc.
+
+package sizefile_test
+
+import (
+	"testing"
+
+	sizefile "github.com/cashapp/blip/metrics/size.file"
+	"github.com/go-test/deep"
+)
+
+func TestFileSizeQuery(t *testing.T) {
+	// All defaults: undo and temporary tablespaces
+	got, params, err := sizefile.FileSizeQuery(map[string]string{})
+	expect := "SELECT TABLESPACE_NAME, FILE_NAME, COALESCE(TOTAL_EXTENTS * EXTENT_SIZE, 0), COALESCE(DATA_FREE, 0) FROM information_schema.FILES WHERE ENGINE = 'InnoDB' AND FILE_TYPE IN ('UNDO LOG', 'TEMPORARY')"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Exclude from default tablespaces
+	opts := map[string]string{
+		sizefile.OPT_EXCLUDE: "innodb_temporary",
+	}
+	got, params, err = sizefile.FileSizeQuery(opts)
+	expect = "SELECT TABLESPACE_NAME, FILE_NAME, COALESCE(TOTAL_EXTENTS * EXTENT_SIZE, 0), COALESCE(DATA_FREE, 0) FROM information_schema.FILES WHERE ENGINE = 'InnoDB' AND FILE_TYPE IN ('UNDO LOG', 'TEMPORARY') AND TABLESPACE_NAME NOT LIKE ?"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"innodb_temporary"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Include any tablespaces
+	opts = map[string]string{
+		sizefile.OPT_INCLUDE: "innodb_undo%,innodb_system",
+		sizefile.OPT_EXCLUDE: "innodb_temporary",
+	}
+	got, params, err = sizefile.FileSizeQuery(opts)
+	expect = "SELECT TABLESPACE_NAME, FILE_NAME, COALESCE(TOTAL_EXTENTS * EXTENT_SIZE, 0), COALESCE(DATA_FREE, 0) FROM information_schema.FILES WHERE ENGINE = 'InnoDB' AND (TABLESPACE_NAME LIKE ? OR TABLESPACE_NAME LIKE ?)"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"innodb_undo%", "innodb_system"}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Block, Inc.
+
+package sizeindex
+
+import (
+	"strings"
+)
+
+const (
+	// Partitions are named like "tbl#p#p0", so strip the partition suffix
+	// and sum partition indexes to report the size of each table index.
+	tblExpr = "SUBSTRING_INDEX(table_name, '#', 1)"
+
+	base = "SELECT database_name AS db, " + tblExpr + " AS tbl, index_name AS idx, SUM(stat_value) * @@innodb_page_size AS idx_size_bytes" +
+		" FROM mysql.innodb_index_stats WHERE stat_name = 'size' AND "
+	groupBy = " GROUP BY 1, 2, 3"
+)
+
+func IndexSizeQuery(set map[string]string) (string, []interface{}, error) {
+	var where string
+	var params []interface{}
+	if include := set[OPT_INCLUDE]; include != "" {
+		where, params = setWhere(strings.Split(set[OPT_INCLUDE], ","), true)
+	} else {
+		where, params = setWhere(strings.Split(set[OPT_EXCLUDE], ","), false)
+	}
+	return base + where + groupBy, params, nil
+}
+
+func setWhere(tables []string, isInclude bool) (string, []interface{}) {
+	where := "("
+	if !isInclude {
+		where = where + "NOT "
+	}
+	var params []interface{} = make([]interface{}, 0)
+	for i, excludeTable := range tables {
+		if strings.Contains(excludeTable, ".") {
+			dbAndTable := strings.Split(excludeTable, ".")
+			db := dbAndTable[0]
+			table := dbAndTable[1]
+			if table == "*" {
+				where = where + "(database_name = ?)"
+				params = append(params, db)
+			} else {
+				where = where + "(database_name = ? AND " + tblExpr + " = ?)"
+				params = append(params, db, table)
+			}
+		} else {
+			where = where + "(" + tblExpr + " = ?)"
+			params = append(params, excludeTable)
+		}
+		if i != (len(tables) - 1) {
+			if isInclude {
+				where = where + " OR "
+			} else {
+				where = where + " AND NOT "
+			}
+		}
+	}
+	where = where + ")"
+	return where, params
+}
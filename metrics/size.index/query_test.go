@@ -0,0 +1,48 @@
+// Copyright 2024 Block, Inc.
+
+package sizeindex_test
+
+import (
+	"testing"
+
+	sizeindex "github.com/cashapp/blip/metrics/size.index"
+	"github.com/go-test/deep"
+)
+
+func TestIndexSizeQuery(t *testing.T) {
+	// All defaults
+	opts := map[string]string{
+		sizeindex.OPT_EXCLUDE: "mysql.*,information_schema.*,performance_schema.*,sys.*",
+	}
+	got, params, err := sizeindex.IndexSizeQuery(opts)
+	expect := "SELECT database_name AS db, SUBSTRING_INDEX(table_name, '#', 1) AS tbl, index_name AS idx, SUM(stat_value) * @@innodb_page_size AS idx_size_bytes FROM mysql.innodb_index_stats WHERE stat_name = 'size' AND (NOT (database_name = ?) AND NOT (database_name = ?) AND NOT (database_name = ?) AND NOT (database_name = ?)) GROUP BY 1, 2, 3"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	expectedParams := []interface{}{"mysql", "information_schema", "performance_schema", "sys"}
+	if diff := deep.Equal(params, expectedParams); diff != nil {
+		t.Error(diff)
+	}
+
+	// Include tables
+	opts = map[string]string{
+		sizeindex.OPT_INCLUDE: "t1,test.t2",
+	}
+	got, params, err = sizeindex.IndexSizeQuery(opts)
+	expect = "SELECT database_name AS db, SUBSTRING_INDEX(table_name, '#', 1) AS tbl, index_name AS idx, SUM(stat_value) * @@innodb_page_size AS idx_size_bytes FROM mysql.innodb_index_stats WHERE stat_name = 'size' AND ((SUBSTRING_INDEX(table_name, '#', 1) = ?) OR (database_name = ? AND SUBSTRING_INDEX(table_name, '#', 1) = ?)) GROUP BY 1, 2, 3"
+	if err != nil {
+		t.Error(err)
+	}
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	expectedParams = []interface{}{"t1", "test", "t2"}
+	if diff := deep.Equal(params, expectedParams); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2024 Block, Inc.
+
+// Package sql provides the sql domain: custom metrics from a user-defined query.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	DOMAIN = "sql"
+
+	OPT_QUERY    = "query"
+	OPT_COUNTERS = "counters"
+	OPT_GROUP    = "group"
+	OPT_TIMEOUT  = "timeout"
+)
+
+var (
+	// Query must be a SELECT, optionally in parentheses
+	selectRe = regexp.MustCompile(`(?is)^[(\s]*SELECT\s`)
+
+	// SELECT that locks rows or writes files
+	lockOrWriteRe = regexp.MustCompile(`(?is)\bFOR\s+(UPDATE|SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b|\bINTO\s+(OUTFILE|DUMPFILE)\b`)
+)
+
+type query struct {
+	query   string
+	metrics map[string]byte // column => metric type
+	group   []string        // columns
+	timeout time.Duration
+}
+
+// SQL collects metrics from a custom query defined by domain options. The
+// query is a single SELECT statement: each metric is a column, and each row
+// is a group of metrics (if there are group columns). The query is executed
+// in a read-only transaction.
+type SQL struct {
+	db      *sql.DB
+	atLevel map[string]query
+}
+
+var _ blip.Collector = &SQL{}
+
+func NewSQL(db *sql.DB) *SQL {
+	return &SQL{
+		db:      db,
+		atLevel: map[string]query{},
+	}
+}
+
+func (c *SQL) Domain() string {
+	return DOMAIN
+}
+
+func (c *SQL) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Custom metrics from a SELECT query",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_QUERY: {
+				Name: OPT_QUERY,
+				Desc: "SELECT statement (required); metrics are columns named like the collected metrics; semicolons are allowed only at the end or in quoted strings",
+			},
+			OPT_COUNTERS: {
+				Name: OPT_COUNTERS,
+				Desc: "Comma-separated list of metrics that are cumulative counters (all other metrics are gauges)",
+			},
+			OPT_GROUP: {
+				Name: OPT_GROUP,
+				Desc: "Comma-separated list of columns to group metrics by",
+			},
+			OPT_TIMEOUT: {
+				Name:    OPT_TIMEOUT,
+				Desc:    "Query timeout",
+				Default: "2s",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "(column)", Value: "the value of each column in option " + OPT_GROUP},
+		},
+	}
+}
+
+func (c *SQL) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one metric (column name) from option %s", OPT_QUERY)
+		}
+
+		if err := ValidateQuery(dom.Options[OPT_QUERY]); err != nil {
+			return nil, err
+		}
+		q := query{
+			query:   strings.TrimRight(strings.TrimSpace(dom.Options[OPT_QUERY]), ";"),
+			metrics: make(map[string]byte, len(dom.Metrics)),
+			timeout: 2 * time.Second,
+		}
+
+		for _, name := range dom.Metrics {
+			q.metrics[name] = blip.GAUGE
+		}
+		for _, name := range list(dom.Options[OPT_COUNTERS]) {
+			if _, ok := q.metrics[name]; !ok {
+				return nil, fmt.Errorf("invalid %s value: %s is not a collected metric", OPT_COUNTERS, name)
+			}
+			q.metrics[name] = blip.CUMULATIVE_COUNTER
+		}
+
+		for _, col := range list(dom.Options[OPT_GROUP]) {
+			if _, ok := q.metrics[col]; ok {
+				return nil, fmt.Errorf("invalid %s value: %s is a metric", OPT_GROUP, col)
+			}
+			q.group = append(q.group, col)
+		}
+
+		if s, ok := dom.Options[OPT_TIMEOUT]; ok {
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid %s value '%s': must be a duration greater than zero", OPT_TIMEOUT, s)
+			}
+			q.timeout = d
+		}
+
+		c.atLevel[level.Name] = q
+	}
+	return nil, nil
+}
+
+func (c *SQL) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	q, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	// Read-only transaction: a write is an error even if ValidateQuery missed it
+	tx, err := c.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, q.query)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %s", q.query, err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	colIdx := make(map[string]int, len(cols))
+	for i, col := range cols {
+		colIdx[col] = i
+	}
+	for name := range q.metrics {
+		if _, ok := colIdx[name]; !ok {
+			return nil, fmt.Errorf("metric %s is not a column in query result: %v", name, cols)
+		}
+	}
+	for _, col := range q.group {
+		if _, ok := colIdx[col]; !ok {
+			return nil, fmt.Errorf("group %s is not a column in query result: %v", col, cols)
+		}
+	}
+
+	vals := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+
+	metrics := []blip.MetricValue{}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		var group map[string]string
+		if len(q.group) > 0 {
+			group = make(map[string]string, len(q.group))
+			for _, col := range q.group {
+				group[col] = vals[colIdx[col]].String // NULL = ""
+			}
+		}
+
+		for name, mtype := range q.metrics {
+			v := vals[colIdx[name]]
+			if !v.Valid {
+				continue // NULL
+			}
+			f, ok := sqlutil.Float64(v.String)
+			if !ok {
+				blip.Debug("%s: cannot convert %s value to float: %s", DOMAIN, name, v.String)
+				continue
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  name,
+				Type:  mtype,
+				Value: f,
+				Group: group,
+			})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+// ValidateQuery returns an error if the query is not a single SELECT statement,
+// or if the SELECT locks rows or writes files.
+func ValidateQuery(q string) error {
+	q = strings.TrimRight(strings.TrimSpace(q), ";")
+	if q == "" {
+		return fmt.Errorf("option %s not set", OPT_QUERY)
+	}
+	if !selectRe.MatchString(q) {
+		return fmt.Errorf("invalid %s: not a SELECT statement: %s", OPT_QUERY, q)
+	}
+	// Check only text outside quotes so, for example, LIKE '%;%' is allowed
+	code, err := unquoted(q)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s: %s", OPT_QUERY, err, q)
+	}
+	if strings.Contains(code, ";") {
+		return fmt.Errorf("invalid %s: multiple statements: %s", OPT_QUERY, q)
+	}
+	if m := lockOrWriteRe.FindString(code); m != "" {
+		return fmt.Errorf("invalid %s: %s not allowed: %s", OPT_QUERY, m, q)
+	}
+	return nil
+}
+
+// unquoted returns q without the text inside quoted strings and identifiers
+// ('...', "...", and `...`), keeping the quote characters. Quotes are escaped
+// by doubling them or, except in identifiers, by backslash. Comments are not
+// removed, so a semicolon in a comment is still treated as a statement separator.
+func unquoted(q string) (string, error) {
+	var b strings.Builder
+	var quote byte // 0 if not in quoted text
+	for i := 0; i < len(q); i++ {
+		ch := q[i]
+		if quote == 0 {
+			if ch == '\'' || ch == '"' || ch == '`' {
+				quote = ch
+			}
+			b.WriteByte(ch)
+			continue
+		}
+		switch {
+		case ch == '\\' && quote != '`':
+			i++ // skip escaped char
+		case ch == quote && i+1 < len(q) && q[i+1] == quote:
+			i++ // doubled quote
+		case ch == quote:
+			quote = 0
+			b.WriteByte(ch)
+		}
+	}
+	if quote != 0 {
+		return "", fmt.Errorf("unterminated %c quote", quote)
+	}
+	return b.String(), nil
+}
+
+func list(csv string) []string {
+	if csv == "" {
+		return nil
+	}
+	l := strings.Split(csv, ",")
+	for i := range l {
+		l[i] = strings.TrimSpace(l[i])
+	}
+	return l
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 Block, Inc.
+
+package sql_test
+
+import (
+	"testing"
+
+	sqldomain "github.com/cashapp/blip/metrics/sql"
+)
+
+func TestValidateQuery(t *testing.T) {
+	valid := []string{
+		"SELECT COUNT(*) AS depth FROM app.queue",
+		"select count(*) depth from app.queue;",
+		"  SELECT\n  status, COUNT(*) AS n FROM app.jobs GROUP BY status",
+		"(SELECT 1 AS n) UNION (SELECT 2)",
+		"SELECT COUNT(*) AS n FROM app.notes WHERE note LIKE '%;%'",
+		`SELECT COUNT(*) AS n FROM app.notes WHERE note = "a;b" OR note = 'it''s; \'ok\';'`,
+		"SELECT COUNT(*) AS n FROM app.notes WHERE note = 'FOR UPDATE';",
+		"SELECT COUNT(*) AS `n;m` FROM app.notes",
+	}
+	for _, q := range valid {
+		if err := sqldomain.ValidateQuery(q); err != nil {
+			t.Errorf("%q: got error '%s', expected no error", q, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		";",
+		"DELETE FROM app.queue",
+		"UPDATE app.queue SET n=1",
+		"WITH x AS (SELECT 1) DELETE FROM app.queue",
+		"SHOW GLOBAL STATUS",
+		"SELECT 1; DROP TABLE app.queue",
+		"SELECT * FROM app.queue FOR UPDATE",
+		"SELECT * FROM app.queue FOR SHARE",
+		"SELECT * FROM app.queue LOCK IN SHARE MODE",
+		"SELECT * FROM app.queue INTO OUTFILE '/tmp/q'",
+		"SELECTX 1",
+		"SELECT ';' AS n; DROP TABLE app.queue",
+		"SELECT 'unterminated; AS n",
+		"SELECT 1 AS n /* ; */",
+	}
+	for _, q := range invalid {
+		if err := sqldomain.ValidateQuery(q); err == nil {
+			t.Errorf("%q: no error, expected an error", q)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 Block, Inc.
+
+package status
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	BASE_QUERY_ACCOUNT = "SELECT USER, HOST, VARIABLE_NAME, VARIABLE_VALUE FROM performance_schema.status_by_account"
+)
+
+// StatusAccount collects status variables for the status.account domain.
+// https://dev.mysql.com/doc/refman/8.4/en/performance-schema-status-variable-summary-tables.html
+type StatusAccount struct {
+	db *sql.DB
+	// --
+	options map[string]*statusLevelOptions
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &StatusAccount{}
+
+// NewStatusAccount makes a new StatusAccount collector.
+func NewStatusAccount(db *sql.DB) *StatusAccount {
+	return &StatusAccount{
+		db:      db,
+		options: make(map[string]*statusLevelOptions),
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (t *StatusAccount) Domain() string {
+	return DOMAIN + "." + SUB_DOMAIN_ACCOUNT
+}
+
+// Help returns the output for blip --print-domains.
+func (t *StatusAccount) Help() blip.CollectorHelp {
+	h := help(SUB_DOMAIN_ACCOUNT)
+	h.Groups = []blip.CollectorKeyValue{
+		{Key: GRP_USER, Value: "the user for the corresponding status variable"},
+		{Key: GRP_HOST, Value: "the host for the corresponding status variable"},
+	}
+	h.Options[OPT_INCLUDE] = blip.CollectorHelpOption{
+		Name: OPT_INCLUDE,
+		Desc: fmt.Sprintf("Comma-separated list of accounts (user@host) to include (overrides option %s)", OPT_EXCLUDE),
+	}
+	h.Options[OPT_EXCLUDE] = blip.CollectorHelpOption{
+		Name: OPT_EXCLUDE,
+		Desc: fmt.Sprintf("Comma-separated list of accounts (user@host) to exclude (ignored if %s is set)", OPT_INCLUDE),
+	}
+
+	return h
+}
+
+// Prepare prepares the collector for the given plan.
+func (t *StatusAccount) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[t.Domain()]
+		if !ok {
+			continue
+		}
+
+		o, err := prepare(dom, SUB_DOMAIN_ACCOUNT, BASE_QUERY_ACCOUNT)
+		if err != nil {
+			return nil, err
+		}
+
+		t.options[level.Name] = o
+	}
+	return nil, nil
+}
+
+func (t *StatusAccount) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	o, ok := t.options[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	return collect(ctx, t.db, o, SUB_DOMAIN_ACCOUNT)
+}
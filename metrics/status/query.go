@@ -0,0 +1,113 @@
+// Copyright 2024 Block, Inc.
+
+package status
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+// StatusQuery builds a query for status metrics based on the domain configuration.
+func StatusQuery(dom blip.Domain, baseQuery string, subDomain string) (*statusLevelOptions, error) {
+	conditions := []string{}
+	params := []any{}
+
+	if strings.ToLower(dom.Options[OPT_ALL]) != "yes" {
+		names := make([]string, len(dom.Metrics))
+		for i := range dom.Metrics {
+			names[i] = strings.ToLower(dom.Metrics[i])
+		}
+		conditions = append(conditions, fmt.Sprintf("LOWER(VARIABLE_NAME) IN (%s)", sqlutil.PlaceholderList(len(names))))
+		params = append(params, sqlutil.ToInterfaceArray(names)...)
+	}
+
+	var subWhere string
+	var subParams []any
+	switch subDomain {
+	case SUB_DOMAIN_ACCOUNT:
+		subWhere, subParams = getAccountFilters(dom)
+	case SUB_DOMAIN_USER:
+		subWhere, subParams = getFilters(dom, "USER")
+	case SUB_DOMAIN_HOST:
+		subWhere, subParams = getFilters(dom, "HOST")
+	default:
+		return nil, fmt.Errorf("invalid subdomain: %s", subDomain)
+	}
+	conditions = append(conditions, subWhere)
+	params = append(params, subParams...)
+
+	return &statusLevelOptions{
+		query:  baseQuery + " WHERE " + strings.Join(conditions, " AND "),
+		params: params,
+	}, nil
+}
+
+// getAccountFilters returns include or exclude conditions for accounts listed
+// as user@host. Either user or host (but not both) can be a wildcard "*", and
+// an account without "@" is a user on any host (user@*).
+func getAccountFilters(dom blip.Domain) (string, []any) {
+	var list, inOp, conjunction string
+	if include := dom.Options[OPT_INCLUDE]; include != "" {
+		list, inOp, conjunction = include, "IN", " OR "
+	} else if exclude := dom.Options[OPT_EXCLUDE]; exclude != "" {
+		list, inOp, conjunction = exclude, "NOT IN", " AND "
+	}
+
+	onlyUser := []string{}
+	onlyHost := []string{}
+	onlyAccount := []string{}
+	if list != "" {
+		for _, token := range strings.Split(list, ",") {
+			parts := strings.SplitN(strings.TrimSpace(token), "@", 2)
+			if len(parts) == 1 {
+				parts = append(parts, "*")
+			}
+			switch {
+			case parts[0] == "*" && parts[1] != "*":
+				onlyHost = append(onlyHost, parts[1])
+			case parts[0] != "*" && parts[1] == "*":
+				onlyUser = append(onlyUser, parts[0])
+			case parts[0] != "*" && parts[1] != "*":
+				onlyAccount = append(onlyAccount, parts...)
+			}
+		}
+	}
+
+	// Ensure user and host are not NULL (background threads)
+	where := "USER IS NOT NULL AND HOST IS NOT NULL"
+	params := []any{}
+	conditions := make([]string, 0, 3)
+	if len(onlyAccount) > 0 {
+		conditions = append(conditions, fmt.Sprintf("(USER, HOST) %s (%s)", inOp, sqlutil.MultiPlaceholderList(len(onlyAccount)/2, 2)))
+		params = append(params, sqlutil.ToInterfaceArray(onlyAccount)...)
+	}
+	if len(onlyUser) > 0 {
+		conditions = append(conditions, fmt.Sprintf("USER %s (%s)", inOp, sqlutil.PlaceholderList(len(onlyUser))))
+		params = append(params, sqlutil.ToInterfaceArray(onlyUser)...)
+	}
+	if len(onlyHost) > 0 {
+		conditions = append(conditions, fmt.Sprintf("HOST %s (%s)", inOp, sqlutil.PlaceholderList(len(onlyHost))))
+		params = append(params, sqlutil.ToInterfaceArray(onlyHost)...)
+	}
+	if len(conditions) > 0 {
+		where = "(" + strings.Join(conditions, conjunction) + ") AND " + where
+	}
+	return where, params
+}
+
+// getFilters returns include or exclude conditions for the given column:
+// USER or HOST.
+func getFilters(dom blip.Domain, col string) (string, []any) {
+	if include := dom.Options[OPT_INCLUDE]; include != "" {
+		vals := strings.Split(include, ",")
+		return fmt.Sprintf("%s IN (%s)", col, sqlutil.PlaceholderList(len(vals))), sqlutil.ToInterfaceArray(vals)
+	} else if exclude := dom.Options[OPT_EXCLUDE]; exclude != "" {
+		vals := strings.Split(exclude, ",")
+		return fmt.Sprintf("%s NOT IN (%s)", col, sqlutil.PlaceholderList(len(vals))), sqlutil.ToInterfaceArray(vals)
+	}
+	// Exclude NULL (background threads)
+	return col + " IS NOT NULL", []any{}
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 Block, Inc.
+
+package status
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip"
+	"github.com/go-test/deep"
+)
+
+func TestStatusQuery_Account(t *testing.T) {
+	// Listed metrics, no filters
+	dom := blip.Domain{
+		Options: map[string]string{},
+		Metrics: []string{"Bytes_sent", "com_select"},
+	}
+	got, err := StatusQuery(dom, BASE_QUERY_ACCOUNT, SUB_DOMAIN_ACCOUNT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "SELECT USER, HOST, VARIABLE_NAME, VARIABLE_VALUE FROM performance_schema.status_by_account WHERE LOWER(VARIABLE_NAME) IN (?, ?) AND USER IS NOT NULL AND HOST IS NOT NULL"
+	if got.query != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got.query, expect)
+	}
+	if diff := deep.Equal(got.params, []any{"bytes_sent", "com_select"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// All metrics, include accounts, users, and hosts
+	dom = blip.Domain{
+		Options: map[string]string{
+			OPT_ALL:     "yes",
+			OPT_INCLUDE: "app@10.0.0.1,batch,*@localhost",
+			OPT_EXCLUDE: "ignored",
+		},
+	}
+	got, err = StatusQuery(dom, BASE_QUERY_ACCOUNT, SUB_DOMAIN_ACCOUNT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "SELECT USER, HOST, VARIABLE_NAME, VARIABLE_VALUE FROM performance_schema.status_by_account WHERE ((USER, HOST) IN ((?, ?)) OR USER IN (?) OR HOST IN (?)) AND USER IS NOT NULL AND HOST IS NOT NULL"
+	if got.query != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got.query, expect)
+	}
+	if diff := deep.Equal(got.params, []any{"app", "10.0.0.1", "batch", "localhost"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Exclude users
+	dom = blip.Domain{
+		Options: map[string]string{
+			OPT_ALL:     "yes",
+			OPT_EXCLUDE: "root,blip",
+		},
+	}
+	got, err = StatusQuery(dom, BASE_QUERY_ACCOUNT, SUB_DOMAIN_ACCOUNT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "SELECT USER, HOST, VARIABLE_NAME, VARIABLE_VALUE FROM performance_schema.status_by_account WHERE (USER NOT IN (?, ?)) AND USER IS NOT NULL AND HOST IS NOT NULL"
+	if got.query != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got.query, expect)
+	}
+	if diff := deep.Equal(got.params, []any{"root", "blip"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestStatusQuery_UserHost(t *testing.T) {
+	dom := blip.Domain{
+		Options: map[string]string{
+			OPT_EXCLUDE: "root",
+		},
+		Metrics: []string{"bytes_sent"},
+	}
+	got, err := StatusQuery(dom, BASE_QUERY_USER, SUB_DOMAIN_USER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "SELECT USER, '' HOST, VARIABLE_NAME, VARIABLE_VALUE FROM performance_schema.status_by_user WHERE LOWER(VARIABLE_NAME) IN (?) AND USER NOT IN (?)"
+	if got.query != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got.query, expect)
+	}
+	if diff := deep.Equal(got.params, []any{"bytes_sent", "root"}); diff != nil {
+		t.Error(diff)
+	}
+
+	dom = blip.Domain{
+		Options: map[string]string{
+			OPT_ALL: "yes",
+		},
+	}
+	got, err = StatusQuery(dom, BASE_QUERY_HOST, SUB_DOMAIN_HOST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "SELECT '' USER, HOST, VARIABLE_NAME, VARIABLE_VALUE FROM performance_schema.status_by_host WHERE HOST IS NOT NULL"
+	if got.query != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got.query, expect)
+	}
+	if diff := deep.Equal(got.params, []any{}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 Block, Inc.
+
+package status
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+const (
+	DOMAIN = "status"
+
+	SUB_DOMAIN_ACCOUNT = "account"
+	SUB_DOMAIN_USER    = "user"
+	SUB_DOMAIN_HOST    = "host"
+
+	OPT_ALL     = "all"
+	OPT_INCLUDE = "include"
+	OPT_EXCLUDE = "exclude"
+
+	GRP_USER = "user"
+	GRP_HOST = "host"
+)
+
+type statusLevelOptions struct {
+	query  string
+	params []any
+}
+
+// Returns a default help message for status collectors
+func help(subdomain string) blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN + "." + subdomain,
+		Description: fmt.Sprintf("Status variables by %s like 'Bytes_sent' and 'Com_select'", cases.Title(language.English).String(subdomain)),
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_ALL: {
+				Name:    OPT_ALL,
+				Desc:    "Collect all status variables",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Collect all (safe but wasteful)",
+					"no":  "Collect only variables listed in metrics",
+				},
+			},
+		},
+	}
+}
+
+// Prepares the status level options for the given domain and query.
+func prepare(dom blip.Domain, subdomain, baseQuery string) (*statusLevelOptions, error) {
+	if dom.Options == nil {
+		dom.Options = make(map[string]string)
+	}
+
+	if strings.ToLower(dom.Options[OPT_ALL]) != "yes" && len(dom.Metrics) == 0 {
+		return nil, fmt.Errorf("no metrics specified, expect at least one status variable or option %s=yes", OPT_ALL)
+	}
+
+	return StatusQuery(dom, baseQuery, subdomain)
+}
+
+// Collects status variables from the prepared query. Rows are user, host,
+// variable name, and variable value. The subdomain determines which of user
+// and host are group keys.
+func collect(ctx context.Context, db *sql.DB, o *statusLevelOptions, subdomain string) ([]blip.MetricValue, error) {
+	rows, err := db.QueryContext(ctx, o.query, o.params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics []blip.MetricValue
+		user    string
+		host    string
+		name    string
+		val     string
+		ok      bool
+	)
+	for rows.Next() {
+		if err = rows.Scan(&user, &host, &name, &val); err != nil {
+			return nil, err
+		}
+
+		// Blip metric names are lowercase
+		m := blip.MetricValue{
+			Name: strings.ToLower(name),
+			Type: blip.CUMULATIVE_COUNTER,
+		}
+		if gauge[m.Name] {
+			m.Type = blip.GAUGE
+		}
+		m.Value, ok = sqlutil.Float64(val)
+		if !ok {
+			continue
+		}
+
+		switch subdomain {
+		case SUB_DOMAIN_ACCOUNT:
+			m.Group = map[string]string{GRP_USER: user, GRP_HOST: host}
+		case SUB_DOMAIN_USER:
+			m.Group = map[string]string{GRP_USER: user}
+		case SUB_DOMAIN_HOST:
+			m.Group = map[string]string{GRP_HOST: host}
+		}
+
+		metrics = append(metrics, m)
+	}
+
+	return metrics, rows.Err()
+}
+
+// gauge is a list of known gauge metrics in the status by account, user, and host tables.
+var gauge = map[string]bool{
+	"max_execution_time_set":        true,
+	"innodb_row_lock_current_waits": true,
+	"open_tables":                   true,
+	"threads_connected":             true,
+	"threads_running":               true,
+}
@@ -0,0 +1,339 @@
+// Copyright 2024 Block, Inc.
+
+package stmtdigest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/sqlutil"
+)
+
+const (
+	DOMAIN = "stmt.digest"
+
+	OPT_TOP                = "top"
+	OPT_ORDER_BY           = "order-by"
+	OPT_DIGEST_TEXT_LENGTH = "digest-text-length"
+
+	ORDER_BY_LATENCY = "latency"
+	ORDER_BY_COUNT   = "count"
+)
+
+// Digest counter columns in query column order, after the first 5 columns.
+const (
+	col_count = iota
+	col_sum_timer
+	col_max_timer
+	col_rows_examined
+	col_rows_sent
+	col_no_index_used
+	col_tmp_disk_tables
+	n_cols
+)
+
+// Counter metrics reported from the corresponding digest counter columns.
+var counters = map[string]int{
+	"count":           col_count,
+	"total_latency":   col_sum_timer,
+	"rows_examined":   col_rows_examined,
+	"rows_sent":       col_rows_sent,
+	"no_index_used":   col_no_index_used,
+	"tmp_disk_tables": col_tmp_disk_tables,
+}
+
+type digestKey struct {
+	db     string
+	digest string
+}
+
+// digestStats are the values of one digest at the last collection.
+type digestStats struct {
+	firstSeen float64
+	values    [n_cols]float64
+}
+
+type digestMetrics struct {
+	query      string
+	metrics    []string
+	textLength int
+	// State for calculating deltas between collections:
+	last   map[digestKey]digestStats
+	lastTs float64 // server time (Unix seconds) of last collection
+}
+
+// Digest collects metrics for the stmt.digest domain.
+// The source is performance_schema.events_statements_summary_by_digest.
+type Digest struct {
+	db *sql.DB
+	// --
+	atLevel map[string]*digestMetrics
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &Digest{}
+
+// NewDigest makes a new Digest collector.
+func NewDigest(db *sql.DB) *Digest {
+	return &Digest{
+		db:      db,
+		atLevel: map[string]*digestMetrics{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *Digest) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *Digest) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Statement digest (query pattern) throughput and latency",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_TOP: {
+				Name:    OPT_TOP,
+				Desc:    "Number of top digests to collect, ordered by option " + OPT_ORDER_BY,
+				Default: "100",
+			},
+			OPT_ORDER_BY: {
+				Name:    OPT_ORDER_BY,
+				Desc:    "How to order digests to select top digests",
+				Default: ORDER_BY_LATENCY,
+				Values: map[string]string{
+					ORDER_BY_LATENCY: "Total latency (SUM_TIMER_WAIT)",
+					ORDER_BY_COUNT:   "Execution count (COUNT_STAR)",
+				},
+			},
+			OPT_DIGEST_TEXT_LENGTH: {
+				Name:    OPT_DIGEST_TEXT_LENGTH,
+				Desc:    "Maximum length of digest_text meta, or 0 to disable",
+				Default: "100",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "db", Value: "the default database of the statement, or empty string if none"},
+			{Key: "digest", Value: "the statement digest (hash), or empty string for statements not digested because the table is full"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "digest_text", Value: "the normalized statement, truncated to option " + OPT_DIGEST_TEXT_LENGTH},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "count",
+				Type: blip.DELTA_COUNTER,
+				Desc: "Number of statements executed",
+			},
+			{
+				Name: "total_latency",
+				Type: blip.DELTA_COUNTER,
+				Desc: "Total statement latency in microseconds",
+			},
+			{
+				Name: "avg_latency",
+				Type: blip.GAUGE,
+				Desc: "Average statement latency in microseconds during the interval",
+			},
+			{
+				Name: "max_latency",
+				Type: blip.GAUGE,
+				Desc: "Maximum statement latency in microseconds since the digest was first seen",
+			},
+			{
+				Name: "rows_examined",
+				Type: blip.DELTA_COUNTER,
+				Desc: "Number of rows examined",
+			},
+			{
+				Name: "rows_sent",
+				Type: blip.DELTA_COUNTER,
+				Desc: "Number of rows sent",
+			},
+			{
+				Name: "no_index_used",
+				Type: blip.DELTA_COUNTER,
+				Desc: "Number of statements that did a full table scan",
+			},
+			{
+				Name: "tmp_disk_tables",
+				Type: blip.DELTA_COUNTER,
+				Desc: "Number of internal on-disk temporary tables created",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *Digest) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected at this level
+		}
+
+		if len(dom.Metrics) == 0 {
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
+		}
+		for _, name := range dom.Metrics {
+			if _, ok := counters[name]; ok || name == "avg_latency" || name == "max_latency" {
+				continue
+			}
+			return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+		}
+
+		help := c.Help()
+		m := &digestMetrics{
+			metrics: dom.Metrics,
+			last:    map[digestKey]digestStats{},
+		}
+
+		top, ok := dom.Options[OPT_TOP]
+		if !ok {
+			top = help.Options[OPT_TOP].Default
+		}
+		n, err := strconv.Atoi(top)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than zero", OPT_TOP, top)
+		}
+
+		orderBy, ok := dom.Options[OPT_ORDER_BY]
+		if !ok {
+			orderBy = ORDER_BY_LATENCY
+		}
+		m.query, err = DigestQuery(orderBy, n)
+		if err != nil {
+			return nil, err
+		}
+
+		textLength, ok := dom.Options[OPT_DIGEST_TEXT_LENGTH]
+		if !ok {
+			textLength = help.Options[OPT_DIGEST_TEXT_LENGTH].Default
+		}
+		m.textLength, err = strconv.Atoi(textLength)
+		if err != nil || m.textLength < 0 {
+			return nil, fmt.Errorf("invalid %s value '%s': must be an integer greater than or equal to zero", OPT_DIGEST_TEXT_LENGTH, textLength)
+		}
+
+		c.atLevel[level.Name] = m
+	}
+
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *Digest) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	m, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	rows, err := c.db.QueryContext(ctx, m.query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		k       digestKey
+		text    string
+		cur     digestStats
+		now     float64
+		metrics []blip.MetricValue
+	)
+	seen := make(map[digestKey]digestStats, len(m.last))
+	for rows.Next() {
+		err = rows.Scan(&k.db, &k.digest, &text, &cur.firstSeen, &now,
+			&cur.values[col_count], &cur.values[col_sum_timer], &cur.values[col_max_timer],
+			&cur.values[col_rows_examined], &cur.values[col_rows_sent], &cur.values[col_no_index_used], &cur.values[col_tmp_disk_tables])
+		if err != nil {
+			return nil, err
+		}
+
+		// Picoseconds to microseconds
+		cur.values[col_sum_timer] /= 1e6
+		cur.values[col_max_timer] /= 1e6
+
+		seen[k] = cur
+		prev, ok := m.last[k]
+		d, ok := delta(prev, ok, cur, m.lastTs)
+		if !ok {
+			continue // no baseline yet
+		}
+
+		group := map[string]string{"db": k.db, "digest": k.digest}
+		var meta map[string]string
+		if m.textLength > 0 {
+			meta = map[string]string{"digest_text": sqlutil.Truncate(text, m.textLength)}
+		}
+
+		for _, name := range m.metrics {
+			mv := blip.MetricValue{
+				Name:  name,
+				Type:  blip.DELTA_COUNTER,
+				Group: group,
+				Meta:  meta,
+			}
+			switch name {
+			case "avg_latency":
+				if d[col_count] == 0 {
+					continue
+				}
+				mv.Type = blip.GAUGE
+				mv.Value = d[col_sum_timer] / d[col_count]
+			case "max_latency":
+				mv.Type = blip.GAUGE
+				mv.Value = cur.values[col_max_timer]
+			default:
+				mv.Value = d[counters[name]]
+			}
+			metrics = append(metrics, mv)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Keep only digests in this collection so state doesn't grow unbounded
+	// when digests fall out of the top N or are evicted from the table
+	m.last = seen
+	if now > 0 {
+		m.lastTs = now
+	}
+
+	return metrics, nil
+}
+
+// delta returns the digest counter values since the previous collection
+// and true, or false if there is no baseline to calculate deltas. prev and ok
+// are the previous values of the digest and whether it was collected last time.
+// lastTs is the server time of the last collection, or zero if first collection.
+//
+// Digest rows are not permanent: the table can be truncated, and the row for a
+// digest can be removed and created again later, which resets its counters.
+// A change of FIRST_SEEN detects this: the row is new, so all its values
+// accumulated since the last collection and are the delta. Likewise for a digest
+// not previously collected but first seen after the last collection. Else, a digest
+// not previously collected (for example, it was not in the top N) has no baseline.
+func delta(prev digestStats, ok bool, cur digestStats, lastTs float64) ([n_cols]float64, bool) {
+	var delta [n_cols]float64
+	if !ok || prev.firstSeen != cur.firstSeen {
+		if lastTs == 0 || cur.firstSeen < lastTs {
+			return delta, false
+		}
+		return cur.values, true
+	}
+	for i := range cur.values {
+		delta[i] = cur.values[i] - prev.values[i]
+		if delta[i] < 0 {
+			// Counter reset without FIRST_SEEN change (shouldn't happen),
+			// so best we can do is report the current value
+			delta[i] = cur.values[i]
+		}
+	}
+	return delta, true
+}
@@ -0,0 +1,29 @@
+// Copyright 2024 Block, Inc.
+
+package stmtdigest
+
+import (
+	"fmt"
+)
+
+const (
+	base = `SELECT COALESCE(SCHEMA_NAME, ''), COALESCE(DIGEST, ''), COALESCE(DIGEST_TEXT, ''),
+	UNIX_TIMESTAMP(FIRST_SEEN), UNIX_TIMESTAMP(NOW(6)),
+	COUNT_STAR, SUM_TIMER_WAIT, MAX_TIMER_WAIT, SUM_ROWS_EXAMINED, SUM_ROWS_SENT, SUM_NO_INDEX_USED, SUM_CREATED_TMP_DISK_TABLES
+	FROM performance_schema.events_statements_summary_by_digest`
+)
+
+// DigestQuery returns the query to select the top digests ordered by total
+// latency or count.
+func DigestQuery(orderBy string, top int) (string, error) {
+	var col string
+	switch orderBy {
+	case ORDER_BY_LATENCY:
+		col = "SUM_TIMER_WAIT"
+	case ORDER_BY_COUNT:
+		col = "COUNT_STAR"
+	default:
+		return "", fmt.Errorf("invalid %s value: %s (valid values: %s, %s)", OPT_ORDER_BY, orderBy, ORDER_BY_LATENCY, ORDER_BY_COUNT)
+	}
+	return fmt.Sprintf("%s ORDER BY %s DESC LIMIT %d", base, col, top), nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Block, Inc.
+
+package stmtdigest
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestDigestQuery(t *testing.T) {
+	got, err := DigestQuery(ORDER_BY_LATENCY, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := base + " ORDER BY SUM_TIMER_WAIT DESC LIMIT 100"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	got, err = DigestQuery(ORDER_BY_COUNT, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = base + " ORDER BY COUNT_STAR DESC LIMIT 5"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+
+	_, err = DigestQuery("rows", 5)
+	if err == nil {
+		t.Error("no error for invalid order-by, expected one")
+	}
+}
+
+func TestDelta(t *testing.T) {
+	prev := digestStats{firstSeen: 100, values: [n_cols]float64{10, 1000, 50, 20, 10, 1, 0}}
+	cur := digestStats{firstSeen: 100, values: [n_cols]float64{15, 1500, 60, 30, 15, 1, 2}}
+
+	// First collection: no baseline
+	_, ok := delta(digestStats{}, false, cur, 0)
+	if ok {
+		t.Error("ok on first collection, expected no baseline")
+	}
+
+	// Normal case: current - previous
+	got, ok := delta(prev, true, cur, 200)
+	if !ok {
+		t.Fatal("not ok, expected delta")
+	}
+	expect := [n_cols]float64{5, 500, 10, 10, 5, 0, 2}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Digest entered top N but was first seen before last collection: no baseline
+	_, ok = delta(digestStats{}, false, cur, 200)
+	if ok {
+		t.Error("ok for digest without baseline, expected not ok")
+	}
+
+	// New digest first seen since last collection: all values are new
+	cur.firstSeen = 250
+	got, ok = delta(digestStats{}, false, cur, 200)
+	if !ok {
+		t.Fatal("not ok for new digest, expected delta")
+	}
+	if diff := deep.Equal(got, cur.values); diff != nil {
+		t.Error(diff)
+	}
+
+	// Digest evicted and created again since last collection: counters reset,
+	// so all values are new even though they're less than previous values
+	cur = digestStats{firstSeen: 250, values: [n_cols]float64{3, 300, 200, 3, 3, 0, 0}}
+	got, ok = delta(prev, true, cur, 200)
+	if !ok {
+		t.Fatal("not ok for recreated digest, expected delta")
+	}
+	if diff := deep.Equal(got, cur.values); diff != nil {
+		t.Error(diff)
+	}
+}
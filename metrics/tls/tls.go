@@ -6,6 +6,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/cashapp/blip"
 	"github.com/cashapp/blip/sqlutil"
@@ -15,19 +16,36 @@ const (
 	DOMAIN = "tls"
 )
 
+// CONNECTIONS_QUERY counts client connections by TLS version, cipher, user,
+// and auth plugin. The account host that a connection authenticated as is
+// not in Performance Schema, so plugin is every plugin for the user name.
+const CONNECTIONS_QUERY = `SELECT COALESCE(v.VARIABLE_VALUE, ''), COALESCE(c.VARIABLE_VALUE, ''), t.PROCESSLIST_USER,
+	COALESCE((SELECT GROUP_CONCAT(DISTINCT u.plugin ORDER BY u.plugin) FROM mysql.user u WHERE u.User = t.PROCESSLIST_USER), ''),
+	COUNT(*)
+	FROM performance_schema.threads t
+	LEFT JOIN performance_schema.status_by_thread v ON v.THREAD_ID = t.THREAD_ID AND v.VARIABLE_NAME = 'Ssl_version'
+	LEFT JOIN performance_schema.status_by_thread c ON c.THREAD_ID = t.THREAD_ID AND c.VARIABLE_NAME = 'Ssl_cipher'
+	WHERE t.TYPE = 'FOREGROUND' AND t.PROCESSLIST_USER IS NOT NULL
+	GROUP BY 1, 2, 3, 4`
+
+const CERT_QUERY = "SHOW GLOBAL STATUS LIKE 'Ssl_server_not_after'"
+
 // have_ssl is deprecated as of MySQL 8.0.26, so:
 // @todo https://dev.mysql.com/doc/refman/8.0/en/performance-schema-tls-channel-status-table.html
 
 // TLS collects metrics for the tls domain.
 type TLS struct {
 	db *sql.DB
+	// --
+	atLevel map[string]map[string]bool
 }
 
 var _ blip.Collector = &TLS{}
 
 func NewTLS(db *sql.DB) *TLS {
 	return &TLS{
-		db: db,
+		db:      db,
+		atLevel: map[string]map[string]bool{},
 	}
 }
 
@@ -38,22 +56,35 @@ func (c *TLS) Domain() string {
 func (c *TLS) Help() blip.CollectorHelp {
 	return blip.CollectorHelp{
 		Domain:      DOMAIN,
-		Description: "TLS status",
+		Description: "TLS status, client connections by TLS version and auth plugin, and server certificate expiry",
 		Options:     map[string]blip.CollectorHelpOption{},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "version", Value: "the TLS version, like TLSv1.3, or empty string if not TLS (connections only)"},
+			{Key: "cipher", Value: "the TLS cipher, or empty string if not TLS (connections only)"},
+			{Key: "user", Value: "the user name (connections only)"},
+			{Key: "plugin", Value: "the auth plugin of the user, like caching_sha2_password (connections only)"},
+		},
 		Metrics: []blip.CollectorMetric{
 			{
 				Name: "enabled",
 				Type: blip.BOOL,
 				Desc: "True (1) if have_ssl = YES, else false (0)",
 			},
+			{
+				Name: "connections",
+				Type: blip.GAUGE,
+				Desc: "Number of client connections",
+			},
+			{
+				Name: "cert_expiry_seconds",
+				Type: blip.GAUGE,
+				Desc: "Seconds until the server certificate expires (Ssl_server_not_after)",
+			},
 		},
 	}
 }
 
 func (c *TLS) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
-	// This domain only collects 1 metric (and there are no options),
-	// so we don't have to prepare anything per-level, just check that
-	// the only metric is specified correctly.
 LEVEL:
 	for _, level := range plan.Levels {
 		dom, ok := level.Collect[DOMAIN]
@@ -61,31 +92,105 @@ LEVEL:
 			continue LEVEL // not collected at this level
 		}
 		if len(dom.Metrics) == 0 {
-			return nil, fmt.Errorf("metric 'enabled' not specified; metrics to collect must be listed under 'metrics:' for each domain")
-		}
-		if len(dom.Metrics) > 1 {
-			return nil, fmt.Errorf("too many metrics specified (%d); this domain collects only 1 metric: enabled", len(dom.Metrics))
+			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
 		}
-		if dom.Metrics[0] != "enabled" {
-			return nil, fmt.Errorf("invalid metric: %s; this domain collects only 1 metric: enabled", dom.Metrics[0])
+		metrics := map[string]bool{}
+		for _, name := range dom.Metrics {
+			switch name {
+			case "enabled", "connections", "cert_expiry_seconds":
+				metrics[name] = true
+			default:
+				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+			}
 		}
+		c.atLevel[level.Name] = metrics
 	}
 	return nil, nil
 }
 
 func (c *TLS) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
-	var haveSSL string
-	err := c.db.QueryRowContext(ctx, "SELECT @@have_ssl").Scan(&haveSSL)
-	if err != nil {
-		return nil, fmt.Errorf("tls.enabled failed: %s", err)
+	m, ok := c.atLevel[levelName]
+	if !ok {
+		return nil, nil
 	}
-	enabled, _ := sqlutil.Float64(haveSSL) // MySQL string value -> 1 or 0
-	metrics := []blip.MetricValue{
-		{
+
+	var metrics []blip.MetricValue
+
+	if m["enabled"] {
+		var haveSSL string
+		err := c.db.QueryRowContext(ctx, "SELECT @@have_ssl").Scan(&haveSSL)
+		if err != nil {
+			return nil, fmt.Errorf("tls.enabled failed: %s", err)
+		}
+		enabled, _ := sqlutil.Float64(haveSSL) // MySQL string value -> 1 or 0
+		metrics = append(metrics, blip.MetricValue{
 			Name:  "enabled",
 			Type:  blip.BOOL, // treated as GAUGE by sinks with value 0 or 1
 			Value: enabled,
-		},
+		})
+	}
+
+	if m["connections"] {
+		conns, err := c.connections(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("tls.connections failed: %s", err)
+		}
+		metrics = append(metrics, conns...)
+	}
+
+	if m["cert_expiry_seconds"] {
+		var name, notAfter string
+		err := c.db.QueryRowContext(ctx, CERT_QUERY).Scan(&name, &notAfter)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("tls.cert_expiry_seconds failed: %s", err)
+		}
+		if notAfter != "" { // empty if TLS not enabled
+			t, err := ParseCertTime(notAfter)
+			if err != nil {
+				return nil, fmt.Errorf("tls.cert_expiry_seconds failed: %s", err)
+			}
+			metrics = append(metrics, blip.MetricValue{
+				Name:  "cert_expiry_seconds",
+				Type:  blip.GAUGE,
+				Value: time.Until(t).Seconds(),
+			})
+		}
 	}
+
 	return metrics, nil
 }
+
+func (c *TLS) connections(ctx context.Context) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, CONNECTIONS_QUERY)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		metrics []blip.MetricValue
+		version string
+		cipher  string
+		user    string
+		plugin  string
+		n       float64
+	)
+	for rows.Next() {
+		if err = rows.Scan(&version, &cipher, &user, &plugin, &n); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "connections",
+			Type:  blip.GAUGE,
+			Value: n,
+			Group: map[string]string{"version": version, "cipher": cipher, "user": user, "plugin": plugin},
+		})
+	}
+	return metrics, rows.Err()
+}
+
+// ParseCertTime parses the time of status variables Ssl_server_not_after and
+// Ssl_server_not_before, like "Dec  1 12:00:00 2030 GMT" (OpenSSL format).
+func ParseCertTime(s string) (time.Time, error) {
+	return time.Parse("Jan _2 15:04:05 2006 MST", s)
+}
@@ -0,0 +1,31 @@
+// Copyright 2024 Block, Inc.
+
+package tls_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cashapp/blip/metrics/tls"
+)
+
+func TestParseCertTime(t *testing.T) {
+	tests := map[string]time.Time{
+		"Dec  1 12:00:00 2030 GMT": time.Date(2030, 12, 1, 12, 0, 0, 0, time.UTC),
+		"Jun 15 08:30:59 2027 GMT": time.Date(2027, 6, 15, 8, 30, 59, 0, time.UTC),
+	}
+	for s, expect := range tests {
+		got, err := tls.ParseCertTime(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+		if !got.Equal(expect) {
+			t.Errorf("%s: got %s, expected %s", s, got, expect)
+		}
+	}
+
+	if _, err := tls.ParseCertTime("2030-12-01"); err == nil {
+		t.Error("no error parsing invalid time, expected an error")
+	}
+}
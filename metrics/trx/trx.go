@@ -6,6 +6,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/cashapp/blip"
 )
@@ -13,10 +15,42 @@ import (
 const (
 	DOMAIN           = "trx"
 	OLDEST_TRX_QUERY = `SELECT COALESCE(UNIX_TIMESTAMP(NOW()) - UNIX_TIMESTAMP(MIN(trx_started)), 0) t FROM information_schema.innodb_trx;`
+
+	STATE_QUERY = `SELECT trx_state, COUNT(*) FROM information_schema.innodb_trx GROUP BY trx_state`
+
+	// Long-running transactions and their read views. Transactions at REPEATABLE
+	// READ or SERIALIZABLE keep one read view for the whole transaction, which
+	// prevents purge of undo logs created after the read view was created.
+	SUMMARY_QUERY = `SELECT
+	COALESCE(SUM(trx_started < NOW() - INTERVAL ? SECOND), 0),
+	COALESCE(MAX(trx_rows_locked), 0),
+	COALESCE(MAX(trx_rows_modified), 0),
+	COALESCE(SUM(trx_started < NOW() - INTERVAL ? SECOND AND trx_isolation_level IN ('REPEATABLE READ', 'SERIALIZABLE')), 0)
+	FROM information_schema.innodb_trx`
+
+	HISTORY_LIST_LENGTH_QUERY = `SELECT COUNT FROM information_schema.innodb_metrics WHERE NAME = 'trx_rseg_history_len'`
+
+	// Idle in transaction: transaction open while the thread is not executing a command
+	IDLE_QUERY = `SELECT COUNT(*), COALESCE(MAX(th.PROCESSLIST_TIME), 0)
+	FROM information_schema.innodb_trx t
+	JOIN performance_schema.threads th ON th.PROCESSLIST_ID = t.trx_mysql_thread_id
+	WHERE th.PROCESSLIST_COMMAND = 'Sleep'`
+
+	OPT_LONG_RUNNING_TIME = "long-running-time"
 )
 
+// Transaction states (innodb_trx.trx_state). Every state is reported, even if
+// no transaction is in the state, so the metrics are zero instead of missing.
+var states = []string{"RUNNING", "LOCK WAIT", "ROLLING BACK", "COMMITTING"}
+
 type trxMetrics struct {
-	queryOldest bool
+	queryOldest  bool
+	queryState   bool
+	querySummary bool
+	queryHLL     bool
+	queryIdle    bool
+	metrics      map[string]bool
+	longRunning  int64 // seconds
 }
 
 // Trx collects metrics for the event.trx domain.
@@ -47,13 +81,62 @@ func (c *Trx) Help() blip.CollectorHelp {
 	return blip.CollectorHelp{
 		Domain:      DOMAIN,
 		Description: "Transaction metrics",
-		Options:     map[string]blip.CollectorHelpOption{},
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_LONG_RUNNING_TIME: {
+				Name:    OPT_LONG_RUNNING_TIME,
+				Desc:    "Transactions older than this duration are long-running (long_running and long_read_views metrics)",
+				Default: "60s",
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "state", Value: "the transaction state (count metric only): RUNNING, LOCK WAIT, ROLLING BACK, COMMITTING"},
+		},
 		Metrics: []blip.CollectorMetric{
 			{
 				Name: "oldest",
 				Type: blip.GAUGE,
 				Desc: "The time of oldest transaction in seconds",
 			},
+			{
+				Name: "count",
+				Type: blip.GAUGE,
+				Desc: "Number of transactions by state",
+			},
+			{
+				Name: "long_running",
+				Type: blip.GAUGE,
+				Desc: "Number of transactions older than option " + OPT_LONG_RUNNING_TIME,
+			},
+			{
+				Name: "max_rows_locked",
+				Type: blip.GAUGE,
+				Desc: "Maximum number of rows locked by a transaction",
+			},
+			{
+				Name: "max_rows_modified",
+				Type: blip.GAUGE,
+				Desc: "Maximum number of rows modified (inserted, updated, or deleted) by a transaction",
+			},
+			{
+				Name: "long_read_views",
+				Type: blip.GAUGE,
+				Desc: "Number of long-running transactions at REPEATABLE READ or SERIALIZABLE that keep a read view open",
+			},
+			{
+				Name: "history_length_with_long_read_views",
+				Type: blip.GAUGE,
+				Desc: "Total history list length, reported only while there are long read views (InnoDB does not report the length per read view)",
+			},
+			{
+				Name: "idle",
+				Type: blip.GAUGE,
+				Desc: "Number of transactions open while the session is idle (command Sleep)",
+			},
+			{
+				Name: "max_idle_time",
+				Type: blip.GAUGE,
+				Desc: "Longest idle time in seconds of sessions with an open transaction",
+			},
 		},
 	}
 }
@@ -71,14 +154,17 @@ LEVEL:
 			return nil, fmt.Errorf("no metrics specified, expect at least one collector metric (run 'blip --print-domains' to list collector metrics)")
 		}
 
-		m := trxMetrics{}
-		for i := range dom.Metrics {
-			switch dom.Metrics[i] {
-			case "oldest":
-				m.queryOldest = true
-			default:
-				return nil, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", dom.Metrics[i])
+		m, err := newTrxMetrics(dom.Metrics)
+		if err != nil {
+			return nil, err
+		}
+
+		if s, ok := dom.Options[OPT_LONG_RUNNING_TIME]; ok {
+			d, err := time.ParseDuration(s)
+			if err != nil || d < time.Second {
+				return nil, fmt.Errorf("invalid %s value '%s': must be a duration of at least 1s", OPT_LONG_RUNNING_TIME, s)
 			}
+			m.longRunning = int64(d.Seconds())
 		}
 
 		c.atLevel[level.Name] = m
@@ -94,14 +180,14 @@ func (c *Trx) Collect(ctx context.Context, levelName string) ([]blip.MetricValue
 		return nil, nil
 	}
 
-	var t float64
-	err := c.db.QueryRowContext(ctx, OLDEST_TRX_QUERY).Scan(&t)
-	if err != nil {
-		return nil, fmt.Errorf("%s failed: %s", OLDEST_TRX_QUERY, err)
-	}
-
 	metrics := []blip.MetricValue{}
+
 	if rm.queryOldest {
+		var t float64
+		err := c.db.QueryRowContext(ctx, OLDEST_TRX_QUERY).Scan(&t)
+		if err != nil {
+			return nil, fmt.Errorf("%s failed: %s", OLDEST_TRX_QUERY, err)
+		}
 		m := blip.MetricValue{
 			Name:  "oldest",
 			Type:  blip.GAUGE,
@@ -110,5 +196,176 @@ func (c *Trx) Collect(ctx context.Context, levelName string) ([]blip.MetricValue
 		metrics = append(metrics, m)
 	}
 
+	if rm.queryState {
+		m, err := c.collectState(ctx)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+
+	if rm.querySummary {
+		var v [4]float64
+		err := c.db.QueryRowContext(ctx, SUMMARY_QUERY, rm.longRunning, rm.longRunning).Scan(&v[0], &v[1], &v[2], &v[3])
+		if err != nil {
+			return nil, fmt.Errorf("%s failed: %s", SUMMARY_QUERY, err)
+		}
+		hll := -1.0 // not queried
+		if rm.queryHLL && v[3] > 0 {
+			err := c.db.QueryRowContext(ctx, HISTORY_LIST_LENGTH_QUERY).Scan(&hll)
+			if err != nil {
+				return nil, fmt.Errorf("%s failed: %s", HISTORY_LIST_LENGTH_QUERY, err)
+			}
+		}
+		metrics = append(metrics, summaryMetrics(rm, v, hll)...)
+	}
+
+	if rm.queryIdle {
+		var n, t float64
+		err := c.db.QueryRowContext(ctx, IDLE_QUERY).Scan(&n, &t)
+		if err != nil {
+			return nil, fmt.Errorf("%s failed: %s", IDLE_QUERY, err)
+		}
+		metrics = append(metrics, idleMetrics(rm, n, t)...)
+	}
+
 	return metrics, nil
 }
+
+func (c *Trx) collectState(ctx context.Context) ([]blip.MetricValue, error) {
+	rows, err := c.db.QueryContext(ctx, STATE_QUERY)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %s", STATE_QUERY, err)
+	}
+	defer rows.Close()
+
+	count := map[string]float64{}
+	var (
+		state string
+		n     float64
+	)
+	for rows.Next() {
+		if err = rows.Scan(&state, &n); err != nil {
+			return nil, err
+		}
+		count[state] = n
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stateMetrics(count), nil
+}
+
+// newTrxMetrics returns which queries to run for the metrics list.
+func newTrxMetrics(names []string) (trxMetrics, error) {
+	m := trxMetrics{
+		metrics:     map[string]bool{},
+		longRunning: 60,
+	}
+	for _, name := range names {
+		switch name {
+		case "oldest":
+			m.queryOldest = true
+		case "count":
+			m.queryState = true
+		case "long_running", "max_rows_locked", "max_rows_modified", "long_read_views":
+			m.querySummary = true
+		case "history_length_with_long_read_views":
+			m.querySummary = true // long_read_views > 0
+			m.queryHLL = true
+		case "idle", "max_idle_time":
+			m.queryIdle = true
+		default:
+			return trxMetrics{}, fmt.Errorf("invalid collector metric: %s (run 'blip --print-domains' to list collector metrics)", name)
+		}
+		m.metrics[name] = true
+	}
+	return m, nil
+}
+
+// stateMetrics returns metric count for every state in states, zero if not in
+// count, plus any other state in count.
+func stateMetrics(count map[string]float64) []blip.MetricValue {
+	all := make([]string, 0, len(states)+len(count))
+	all = append(all, states...)
+	var other []string
+	for state := range count {
+		known := false
+		for i := range states {
+			if states[i] == state {
+				known = true
+				break
+			}
+		}
+		if !known {
+			other = append(other, state)
+		}
+	}
+	sort.Strings(other)
+	all = append(all, other...)
+
+	metrics := make([]blip.MetricValue, 0, len(all))
+	for _, state := range all {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "count",
+			Type:  blip.GAUGE,
+			Value: count[state], // 0 if no trx in state
+			Group: map[string]string{"state": state},
+		})
+	}
+	return metrics
+}
+
+// summaryMetrics returns the requested metrics from the SUMMARY_QUERY values v
+// (in column order) and history list length hll. Metric history_length_with_long_read_views
+// is reported only if there are long read views (v[3] > 0) and hll was queried
+// (hll >= 0), so it's missing instead of zero when there are none.
+func summaryMetrics(rm trxMetrics, v [4]float64, hll float64) []blip.MetricValue {
+	values := map[string]float64{
+		"long_running":      v[0],
+		"max_rows_locked":   v[1],
+		"max_rows_modified": v[2],
+		"long_read_views":   v[3],
+	}
+	if v[3] > 0 && hll >= 0 {
+		values["history_length_with_long_read_views"] = hll
+	}
+	var metrics []blip.MetricValue
+	for _, name := range []string{"long_running", "max_rows_locked", "max_rows_modified", "long_read_views", "history_length_with_long_read_views"} {
+		if !rm.metrics[name] {
+			continue
+		}
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		metrics = append(metrics, blip.MetricValue{
+			Name:  name,
+			Type:  blip.GAUGE,
+			Value: value,
+		})
+	}
+	return metrics
+}
+
+// idleMetrics returns the requested metrics from IDLE_QUERY: number of idle
+// transactions n and max idle time t.
+func idleMetrics(rm trxMetrics, n, t float64) []blip.MetricValue {
+	var metrics []blip.MetricValue
+	if rm.metrics["idle"] {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "idle",
+			Type:  blip.GAUGE,
+			Value: n,
+		})
+	}
+	if rm.metrics["max_idle_time"] {
+		metrics = append(metrics, blip.MetricValue{
+			Name:  "max_idle_time",
+			Type:  blip.GAUGE,
+			Value: t,
+		})
+	}
+	return metrics
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 Block, Inc.
+
+package trx
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/cashapp/blip"
+)
+
+func TestNewTrxMetrics(t *testing.T) {
+	m, err := newTrxMetrics([]string{"count", "history_length_with_long_read_views"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.queryState || !m.querySummary || !m.queryHLL || m.queryOldest || m.queryIdle {
+		t.Errorf("got %+v, expected queryState, querySummary, and queryHLL", m)
+	}
+	expect := map[string]bool{"count": true, "history_length_with_long_read_views": true}
+	if diff := deep.Equal(m.metrics, expect); diff != nil {
+		t.Error(diff)
+	}
+	if m.longRunning != 60 {
+		t.Errorf("got longRunning %d, expected default 60", m.longRunning)
+	}
+
+	m, err = newTrxMetrics([]string{"idle", "max_idle_time"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.queryIdle || m.queryOldest || m.queryState || m.querySummary || m.queryHLL {
+		t.Errorf("got %+v, expected only queryIdle", m)
+	}
+
+	if _, err = newTrxMetrics([]string{"read_view_history_length"}); err == nil {
+		t.Error("no error for invalid metric, expected one")
+	}
+}
+
+func TestStateMetrics(t *testing.T) {
+	// Missing states are zero; unknown states are reported after known states
+	got := stateMetrics(map[string]float64{"RUNNING": 5, "LOCK WAIT": 2, "UNKNOWN": 1})
+	expect := []blip.MetricValue{
+		{Name: "count", Type: blip.GAUGE, Value: 5, Group: map[string]string{"state": "RUNNING"}},
+		{Name: "count", Type: blip.GAUGE, Value: 2, Group: map[string]string{"state": "LOCK WAIT"}},
+		{Name: "count", Type: blip.GAUGE, Value: 0, Group: map[string]string{"state": "ROLLING BACK"}},
+		{Name: "count", Type: blip.GAUGE, Value: 0, Group: map[string]string{"state": "COMMITTING"}},
+		{Name: "count", Type: blip.GAUGE, Value: 1, Group: map[string]string{"state": "UNKNOWN"}},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSummaryMetrics(t *testing.T) {
+	rm, err := newTrxMetrics([]string{"long_running", "max_rows_locked", "long_read_views", "history_length_with_long_read_views"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Long read views: history length reported
+	got := summaryMetrics(rm, [4]float64{3, 100, 50, 2}, 5000)
+	expect := []blip.MetricValue{
+		{Name: "long_running", Type: blip.GAUGE, Value: 3},
+		{Name: "max_rows_locked", Type: blip.GAUGE, Value: 100},
+		{Name: "long_read_views", Type: blip.GAUGE, Value: 2},
+		{Name: "history_length_with_long_read_views", Type: blip.GAUGE, Value: 5000},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// No long read views: history length not reported (not zero)
+	got = summaryMetrics(rm, [4]float64{1, 10, 5, 0}, -1)
+	expect = []blip.MetricValue{
+		{Name: "long_running", Type: blip.GAUGE, Value: 1},
+		{Name: "max_rows_locked", Type: blip.GAUGE, Value: 10},
+		{Name: "long_read_views", Type: blip.GAUGE, Value: 0},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestIdleMetrics(t *testing.T) {
+	rm, err := newTrxMetrics([]string{"max_idle_time"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := idleMetrics(rm, 4, 120)
+	expect := []blip.MetricValue{
+		{Name: "max_idle_time", Type: blip.GAUGE, Value: 120},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	rm, _ = newTrxMetrics([]string{"idle", "max_idle_time"})
+	got = idleMetrics(rm, 0, 0)
+	expect = []blip.MetricValue{
+		{Name: "idle", Type: blip.GAUGE, Value: 0},
+		{Name: "max_idle_time", Type: blip.GAUGE, Value: 0},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
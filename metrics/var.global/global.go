@@ -7,22 +7,34 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/event"
 	"github.com/cashapp/blip/sqlutil"
 )
 
 const (
 	DOMAIN = "var.global"
 
-	OPT_SOURCE = "source"
-	OPT_ALL    = "all"
+	OPT_SOURCE  = "source"
+	OPT_ALL     = "all"
+	OPT_CHANGES = "changes"
 
 	SOURCE_SELECT = "select"
 	SOURCE_PFS    = "pfs"
 	SOURCE_SHOW   = "show"
+
+	CHANGE_METRIC = "change"
 )
 
+// sysvar is the name and raw value of a sysvar. NULL values are empty strings,
+// like SHOW GLOBAL VARIABLES.
+type sysvar struct {
+	name  string
+	value string
+}
+
 // Global collects global system variables for the var.global domain.
 type Global struct {
 	db *sql.DB
@@ -31,6 +43,11 @@ type Global struct {
 	queryIn  map[string]string        // keyed on level
 	paramsIn map[string][]interface{} // keyed on level
 	sourceIn map[string]string        // keyed on level
+	changes  map[string]bool          // keyed on level
+	event    event.MonitorReceiver
+	// Last value of every sysvar collected at any level if option changes=yes
+	*sync.Mutex
+	last map[string]string
 }
 
 var _ blip.Collector = &Global{}
@@ -42,6 +59,9 @@ func NewGlobal(db *sql.DB) *Global {
 		queryIn:  make(map[string]string),
 		paramsIn: make(map[string][]interface{}),
 		sourceIn: make(map[string]string),
+		changes:  make(map[string]bool),
+		Mutex:    &sync.Mutex{},
+		last:     make(map[string]string),
 	}
 }
 
@@ -74,12 +94,29 @@ func (c *Global) Help() blip.CollectorHelp {
 					"no":  "Collect only sysvars listed in metrics",
 				},
 			},
+			OPT_CHANGES: {
+				Name:    OPT_CHANGES,
+				Desc:    "Report sysvar changes, including non-numeric sysvars",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "Report a " + CHANGE_METRIC + " metric and event when a sysvar value changes",
+					"no":  "Do not report sysvar changes",
+				},
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "var", Value: "the sysvar name (" + CHANGE_METRIC + " metric only)"},
+		},
+		Meta: []blip.CollectorKeyValue{
+			{Key: "old", Value: "the previous sysvar value (" + CHANGE_METRIC + " metric only)"},
+			{Key: "new", Value: "the new sysvar value (" + CHANGE_METRIC + " metric only)"},
 		},
 	}
 }
 
 // Prepares queries for all levels in the plan that contain the "var.global" domain
 func (c *Global) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+	c.event = event.MonitorReceiver{MonitorId: plan.MonitorId}
 LEVEL:
 	for levelName, level := range plan.Levels {
 		dom, ok := level.Collect[DOMAIN]
@@ -90,20 +127,48 @@ LEVEL:
 		if err != nil {
 			return nil, err
 		}
+		c.changes[levelName] = blip.Bool(dom.Options[OPT_CHANGES])
 	}
 	return nil, nil
 }
 
 func (c *Global) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	var vars []sysvar
+	var err error
 	switch c.sourceIn[levelName] {
 	case SOURCE_SELECT:
-		return c.collectSELECT(ctx, levelName)
+		vars, err = c.collectSELECT(ctx, levelName)
 	case SOURCE_PFS:
-		return c.collectRows(ctx, levelName)
+		vars, err = c.collectRows(ctx, levelName)
 	case SOURCE_SHOW:
-		return c.collectRows(ctx, levelName)
+		vars, err = c.collectRows(ctx, levelName)
+	default:
+		panic(fmt.Sprintf("invalid source in Collect %s", c.sourceIn[levelName]))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]blip.MetricValue, 0, len(vars))
+	for _, v := range vars {
+		// Many sysvars are not numbers or convertible to numbers--that's ok.
+		// Ignore anything we can't convert, which is industry standard practice.
+		f, ok := sqlutil.Float64(v.value)
+		if !ok {
+			continue
+		}
+		metrics = append(metrics, blip.MetricValue{
+			Name:  v.name,
+			Value: f,
+			Type:  blip.GAUGE,
+		})
 	}
-	panic(fmt.Sprintf("invalid source in Collect %s", c.sourceIn[levelName]))
+
+	if c.changes[levelName] {
+		metrics = append(metrics, c.changed(vars)...)
+	}
+
+	return metrics, nil
 }
 
 // //////////////////////////////////////////////////////////////////////////
@@ -116,6 +181,7 @@ func (c *Global) prepareLevel(levelName string, metrics []string, options map[st
 	// Reset in case because prepareLevel can be called multiple times
 	// if the LPA changes the plan
 	c.sourceIn[levelName] = ""
+	c.changes[levelName] = false
 	c.queryIn[levelName] = ""
 	c.metrics[levelName] = []string{}
 
@@ -171,7 +237,9 @@ func (c *Global) prepareSELECT(levelName string) error {
 	}
 	globalMetricString := strings.Join(globalMetrics, ", ")
 
-	c.queryIn[levelName] = fmt.Sprintf("SELECT CONCAT_WS(',', %s) v", globalMetricString)
+	// One column per sysvar because values can contain commas (like sql_mode)
+	// or be NULL
+	c.queryIn[levelName] = fmt.Sprintf("SELECT %s", globalMetricString)
 	c.sourceIn[levelName] = SOURCE_SELECT
 	c.paramsIn[levelName] = []interface{}{}
 
@@ -215,68 +283,78 @@ func (c *Global) prepareSHOW(levelName string, all bool) error {
 
 // --------------------------------------------------------------------------
 
-func (c *Global) collectSELECT(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+func (c *Global) collectSELECT(ctx context.Context, levelName string) ([]sysvar, error) {
 	rows, err := c.db.QueryContext(ctx, c.queryIn[levelName], c.paramsIn[levelName]...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	metrics := make([]blip.MetricValue, len(c.metrics[levelName]))
+	names := c.metrics[levelName]
+	vals := make([]sql.NullString, len(names))
+	dest := make([]interface{}, len(names))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+
+	vars := make([]sysvar, 0, len(names))
 	for rows.Next() {
-		var val string
-		if err := rows.Scan(&val); err != nil {
+		if err := rows.Scan(dest...); err != nil {
 			return nil, err
 		}
-
-		values := strings.Split(val, ",")
-		for i, metric := range c.metrics[levelName] {
-			// Many sysvars are not numbers or convertible to numbers--that's ok.
-			// Ignore anything we can't convert, which is industry standard practice.
-			f, ok := sqlutil.Float64(values[i])
-			if !ok {
-				continue
-			}
-			metrics[i] = blip.MetricValue{
-				Name:  metric,
-				Value: f,
-				Type:  blip.GAUGE,
-			}
+		for i := range names {
+			vars = append(vars, sysvar{name: names[i], value: vals[i].String})
 		}
 	}
 
-	return metrics, nil
+	return vars, rows.Err()
 }
 
 // Since both `show` and `pfs` queries return results in same format (ie; 2 columns, name and value)
 // use the same logic for querying and retrieving metrics from the results
-func (c *Global) collectRows(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+func (c *Global) collectRows(ctx context.Context, levelName string) ([]sysvar, error) {
 	rows, err := c.db.QueryContext(ctx, c.queryIn[levelName], c.paramsIn[levelName]...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	metrics := []blip.MetricValue{}
+	vars := []sysvar{}
 
-	var val string
-	var ok bool
+	var val sql.NullString
 	for rows.Next() {
-		m := blip.MetricValue{Type: blip.GAUGE}
-
-		if err = rows.Scan(&m.Name, &val); err != nil {
+		var v sysvar
+		if err = rows.Scan(&v.name, &val); err != nil {
 			return nil, err
 		}
+		v.value = val.String
+		vars = append(vars, v)
+	}
 
-		// Many sysvars are not numbers or convertible to numbers--that's ok.
-		// Ignore anything we can't convert, which is industry standard practice.
-		m.Value, ok = sqlutil.Float64(val)
-		if !ok {
+	return vars, rows.Err()
+}
+
+// changed returns a change metric for each sysvar with a value different than
+// its last value, and sends an event for each change. The first value of each
+// sysvar is the baseline, so it's not a change.
+func (c *Global) changed(vars []sysvar) []blip.MetricValue {
+	c.Lock()
+	defer c.Unlock()
+	var metrics []blip.MetricValue
+	for _, v := range vars {
+		old, ok := c.last[v.name]
+		c.last[v.name] = v.value
+		if !ok || old == v.value {
 			continue
 		}
-
-		metrics = append(metrics, m)
+		c.event.Sendf(event.SYSVAR_CHANGE, "%s: '%s' -> '%s'", v.name, old, v.value)
+		metrics = append(metrics, blip.MetricValue{
+			Name:  CHANGE_METRIC,
+			Value: 1,
+			Type:  blip.DELTA_COUNTER,
+			Group: map[string]string{"var": v.name},
+			Meta:  map[string]string{"old": old, "new": v.value},
+		})
 	}
-
-	return metrics, err
+	return metrics
 }
@@ -9,7 +9,10 @@ import (
 	"github.com/go-test/deep"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/cashapp/blip"
+	"github.com/cashapp/blip/event"
 	"github.com/cashapp/blip/test"
+	"github.com/cashapp/blip/test/mock"
 )
 
 func TestPrepareForSingleLevelAndNoSource(t *testing.T) {
@@ -34,7 +37,7 @@ func TestPrepareForSingleLevelAndNoSource(t *testing.T) {
 	}
 
 	assert.Equal(t,
-		`SELECT CONCAT_WS(',', @@GLOBAL.read_only) v`,
+		`SELECT @@GLOBAL.read_only`,
 		c.queryIn["kpi"], // level
 	)
 }
@@ -55,8 +58,8 @@ func TestPrepareWithAllSources(t *testing.T) {
 
 	// Test this for all source types ie; auto, select, pfs and show
 	queries := map[string]string{
-		"auto":   "SELECT CONCAT_WS(',', @@GLOBAL.read_only) v",
-		"select": "SELECT CONCAT_WS(',', @@GLOBAL.read_only) v",
+		"auto":   "SELECT @@GLOBAL.read_only",
+		"select": "SELECT @@GLOBAL.read_only",
 		"pfs":    "SELECT variable_name, variable_value from performance_schema.global_variables WHERE variable_name in (?)",
 		"show":   "SHOW GLOBAL VARIABLES WHERE variable_name in (?)",
 	}
@@ -266,3 +269,67 @@ func TestCollectWithOneNonExistentMetric(t *testing.T) {
 	}
 	assert.ElementsMatch(t, metricKeys, []string{"max_connections", "max_prepared_stmt_count", "innodb_max_dirty_pages_pct"})
 }
+
+func TestChanged(t *testing.T) {
+	// The first values are the baseline, so no changes. Then any change,
+	// including non-numeric values, is a change metric and an event.
+	var events []event.Event
+	event.Subscribe(mock.EventReceiver{
+		RecvFunc: func(e event.Event) {
+			if e.Event == event.SYSVAR_CHANGE {
+				events = append(events, e)
+			}
+		},
+	})
+	defer event.RemoveSubscribers()
+
+	c := NewGlobal(nil)
+	c.event = event.MonitorReceiver{MonitorId: "db1"}
+
+	got := c.changed([]sysvar{
+		{name: "read_only", value: "OFF"},
+		{name: "sql_mode", value: "STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION"},
+		{name: "max_connections", value: "100"},
+	})
+	if len(got) != 0 {
+		t.Errorf("got %d change metrics on first collection, expected 0: %+v", len(got), got)
+	}
+
+	got = c.changed([]sysvar{
+		{name: "read_only", value: "ON"},
+		{name: "sql_mode", value: ""},
+		{name: "max_connections", value: "100"},
+	})
+	expect := []blip.MetricValue{
+		{
+			Name:  CHANGE_METRIC,
+			Value: 1,
+			Type:  blip.DELTA_COUNTER,
+			Group: map[string]string{"var": "read_only"},
+			Meta:  map[string]string{"old": "OFF", "new": "ON"},
+		},
+		{
+			Name:  CHANGE_METRIC,
+			Value: 1,
+			Type:  blip.DELTA_COUNTER,
+			Group: map[string]string{"var": "sql_mode"},
+			Meta:  map[string]string{"old": "STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION", "new": ""},
+		},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("got %d events, expected 2: %+v", len(events), events)
+	}
+	if events[0].MonitorId != "db1" || events[0].Message != "read_only: 'OFF' -> 'ON'" {
+		t.Errorf("wrong event: %+v", events[0])
+	}
+
+	// No change since last collection
+	got = c.changed([]sysvar{{name: "read_only", value: "ON"}})
+	if len(got) != 0 {
+		t.Errorf("got %d change metrics, expected 0: %+v", len(got), got)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 Block, Inc.
+
+package wait
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WaitQuery returns the query and params to select the given metrics (columns)
+// filtered by event name patterns.
+func WaitQuery(set map[string]string, metrics []string) (string, []interface{}, error) {
+	columns := []string{"EVENT_NAME"}
+	if all, ok := set[OPT_ALL]; ok && strings.ToLower(all) == "yes" {
+		columns = append(columns, columnNames...)
+	} else {
+		for _, metric := range metrics {
+			metric = strings.ToLower(metric)
+			if _, ok := columnType[metric]; !ok {
+				return "", nil, fmt.Errorf("invalid metric %q (run 'blip --print-domains' to list collector metrics)", metric)
+			}
+			columns = append(columns, metric)
+		}
+	}
+	if len(columns) == 1 {
+		return "", nil, fmt.Errorf("no metrics specified, expect at least one collector metric or option %s=yes", OPT_ALL)
+	}
+
+	var where string
+	var params []interface{}
+	if include := set[OPT_INCLUDE]; include != "" {
+		where, params = setWhere(strings.Split(include, ","), true)
+	} else if exclude := set[OPT_EXCLUDE]; exclude != "" {
+		where, params = setWhere(strings.Split(exclude, ","), false)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM performance_schema.events_waits_summary_global_by_event_name WHERE COUNT_STAR > 0%s",
+		strings.Join(columns, ", "), where)
+	return query, params, nil
+}
+
+func setWhere(patterns []string, isInclude bool) (string, []interface{}) {
+	cond := make([]string, len(patterns))
+	params := make([]interface{}, len(patterns))
+	for i := range patterns {
+		params[i] = strings.TrimSpace(patterns[i])
+		if isInclude {
+			cond[i] = "EVENT_NAME LIKE ?"
+		} else {
+			cond[i] = "EVENT_NAME NOT LIKE ?"
+		}
+	}
+	if isInclude {
+		return " AND (" + strings.Join(cond, " OR ") + ")", params
+	}
+	return " AND " + strings.Join(cond, " AND "), params
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Block, Inc.
+
+package wait_test
+
+import (
+	"testing"
+
+	"github.com/cashapp/blip/metrics/wait"
+	"github.com/go-test/deep"
+)
+
+func TestWaitQuery(t *testing.T) {
+	// Default exclude
+	opts := map[string]string{
+		wait.OPT_EXCLUDE: wait.OPT_EXCLUDE_DEFAULT,
+	}
+	metrics := []string{"COUNT_STAR", "sum_timer_wait"}
+
+	got, params, err := wait.WaitQuery(opts, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "SELECT EVENT_NAME, count_star, sum_timer_wait FROM performance_schema.events_waits_summary_global_by_event_name WHERE COUNT_STAR > 0 AND EVENT_NAME NOT LIKE ?"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"idle"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Include overrides exclude
+	opts = map[string]string{
+		wait.OPT_INCLUDE: "wait/io/file/%,wait/synch/mutex/%",
+		wait.OPT_EXCLUDE: wait.OPT_EXCLUDE_DEFAULT,
+	}
+	got, params, err = wait.WaitQuery(opts, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "SELECT EVENT_NAME, count_star, sum_timer_wait FROM performance_schema.events_waits_summary_global_by_event_name WHERE COUNT_STAR > 0 AND (EVENT_NAME LIKE ? OR EVENT_NAME LIKE ?)"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if diff := deep.Equal(params, []interface{}{"wait/io/file/%", "wait/synch/mutex/%"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// All metrics, no filter
+	opts = map[string]string{
+		wait.OPT_ALL: "yes",
+	}
+	got, params, err = wait.WaitQuery(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect = "SELECT EVENT_NAME, count_star, sum_timer_wait, min_timer_wait, avg_timer_wait, max_timer_wait FROM performance_schema.events_waits_summary_global_by_event_name WHERE COUNT_STAR > 0"
+	if got != expect {
+		t.Errorf("got:\n%s\nexpect:\n%s\n", got, expect)
+	}
+	if len(params) != 0 {
+		t.Errorf("got %d params, expected 0", len(params))
+	}
+
+	// Invalid metric
+	_, _, err = wait.WaitQuery(map[string]string{}, []string{"count_read"})
+	if err == nil {
+		t.Error("no error for invalid metric, expected one")
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Block, Inc.
+
+package wait
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cashapp/blip"
+)
+
+const (
+	DOMAIN = "wait"
+
+	OPT_INCLUDE = "include"
+	OPT_EXCLUDE = "exclude"
+	OPT_ALL     = "all"
+
+	OPT_EXCLUDE_DEFAULT = "idle"
+)
+
+var (
+	columnNames = []string{
+		"count_star",
+		"sum_timer_wait",
+		"min_timer_wait",
+		"avg_timer_wait",
+		"max_timer_wait",
+	}
+
+	columnType = map[string]byte{
+		"count_star":     blip.CUMULATIVE_COUNTER,
+		"sum_timer_wait": blip.CUMULATIVE_COUNTER,
+		"min_timer_wait": blip.GAUGE,
+		"avg_timer_wait": blip.GAUGE,
+		"max_timer_wait": blip.GAUGE,
+	}
+)
+
+// Wait collects global wait event summaries for the wait domain.
+// https://dev.mysql.com/doc/refman/8.4/en/performance-schema-wait-summary-tables.html
+type Wait struct {
+	db *sql.DB
+	// --
+	query  map[string]string
+	params map[string][]interface{}
+}
+
+// Verify collector implements blip.Collector interface.
+var _ blip.Collector = &Wait{}
+
+// NewWait makes a new Wait collector.
+func NewWait(db *sql.DB) *Wait {
+	return &Wait{
+		db:     db,
+		query:  map[string]string{},
+		params: map[string][]interface{}{},
+	}
+}
+
+// Domain returns the Blip metric domain name (DOMAIN const).
+func (c *Wait) Domain() string {
+	return DOMAIN
+}
+
+// Help returns the output for blip --print-domains.
+func (c *Wait) Help() blip.CollectorHelp {
+	return blip.CollectorHelp{
+		Domain:      DOMAIN,
+		Description: "Global wait event summaries like file I/O, mutexes, and locks",
+		Options: map[string]blip.CollectorHelpOption{
+			OPT_INCLUDE: {
+				Name: OPT_INCLUDE,
+				Desc: "Comma-separated list of event name LIKE patterns to include, like wait/io/file/% (overrides option " + OPT_EXCLUDE + ")",
+			},
+			OPT_EXCLUDE: {
+				Name:    OPT_EXCLUDE,
+				Desc:    "Comma-separated list of event name LIKE patterns to exclude (ignored if " + OPT_INCLUDE + " is set)",
+				Default: OPT_EXCLUDE_DEFAULT,
+			},
+			OPT_ALL: {
+				Name:    OPT_ALL,
+				Desc:    "Collect all metrics",
+				Default: "no",
+				Values: map[string]string{
+					"yes": "All metrics (ignore metrics list)",
+					"no":  "Specified metrics",
+				},
+			},
+		},
+		Groups: []blip.CollectorKeyValue{
+			{Key: "event", Value: "the wait event name, like wait/synch/mutex/innodb/trx_sys_mutex"},
+		},
+		Metrics: []blip.CollectorMetric{
+			{
+				Name: "count_star",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Number of waits",
+			},
+			{
+				Name: "sum_timer_wait",
+				Type: blip.CUMULATIVE_COUNTER,
+				Desc: "Total wait time in picoseconds",
+			},
+			{
+				Name: "min_timer_wait",
+				Type: blip.GAUGE,
+				Desc: "Minimum wait time in picoseconds",
+			},
+			{
+				Name: "avg_timer_wait",
+				Type: blip.GAUGE,
+				Desc: "Average wait time in picoseconds",
+			},
+			{
+				Name: "max_timer_wait",
+				Type: blip.GAUGE,
+				Desc: "Maximum wait time in picoseconds",
+			},
+		},
+	}
+}
+
+// Prepare prepares the collector for the given plan.
+func (c *Wait) Prepare(ctx context.Context, plan blip.Plan) (func(), error) {
+LEVEL:
+	for _, level := range plan.Levels {
+		dom, ok := level.Collect[DOMAIN]
+		if !ok {
+			continue LEVEL // not collected in this level
+		}
+		if dom.Options == nil {
+			dom.Options = make(map[string]string)
+		}
+		if _, ok := dom.Options[OPT_EXCLUDE]; !ok {
+			dom.Options[OPT_EXCLUDE] = OPT_EXCLUDE_DEFAULT
+		}
+
+		q, params, err := WaitQuery(dom.Options, dom.Metrics)
+		if err != nil {
+			return nil, err
+		}
+		c.query[level.Name] = q
+		c.params[level.Name] = params
+	}
+	return nil, nil
+}
+
+// Collect collects metrics at the given level.
+func (c *Wait) Collect(ctx context.Context, levelName string) ([]blip.MetricValue, error) {
+	q, ok := c.query[levelName]
+	if !ok {
+		return nil, nil
+	}
+
+	rows, err := c.db.QueryContext(ctx, q, c.params[levelName]...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns for %s: %v", DOMAIN, err)
+	}
+
+	var (
+		metrics []blip.MetricValue
+		event   string
+	)
+	values := make([]interface{}, len(cols))
+	values[0] = &event
+	for i := 1; i < len(cols); i++ {
+		values[i] = new(float64)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(values...); err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(cols); i++ {
+			metrics = append(metrics, blip.MetricValue{
+				Name:  cols[i],
+				Type:  columnType[cols[i]],
+				Value: *values[i].(*float64),
+				Group: map[string]string{"event": event},
+			})
+		}
+	}
+
+	return metrics, rows.Err()
+}
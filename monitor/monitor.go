@@ -304,7 +304,7 @@ func (m *Monitor) startup() error {
 	// Run optional heartbeat write. When enabled (by setting heartbeat.freq),
 	// Blip writes millisecond-precision timestamps to a table that the repl.lag
 	// metric collector uses to report sub-second replication lag.
-	if m.cfg.Heartbeat.Freq != "" {
+	if m.cfg.Heartbeat.Enabled() {
 		status.Monitor(m.monitorId, status.MONITOR, "starting heartbeat")
 		m.hbw = heartbeat.NewWriter(m.monitorId, m.db, m.cfg.Heartbeat)
 		m.wg.Add(1)
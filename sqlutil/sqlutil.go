@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	_ "github.com/go-sql-driver/mysql"
 
@@ -43,6 +44,19 @@ func Float64(s string) (float64, bool) {
 	return 0, false // failed
 }
 
+// Truncate truncates s to at most n bytes without splitting a multibyte UTF-8
+// character. It's used for text like digest_text reported as metric meta,
+// which sinks send as tags or labels that must be valid UTF-8.
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
 // DEPRECATED: Use interpolated queries instead
 func CleanObjectName(o string) string {
 	o = strings.ReplaceAll(o, ";", "")
@@ -212,6 +226,48 @@ func RowToMap(ctx context.Context, db *sql.DB, query string) (map[string]string,
 	return m, nil
 }
 
+// RowsToMaps converts all rows from query to a list of maps of strings keyed on
+// column name, like RowToMap. This is used for multi-row command outputs like
+// SHOW REPLICA STATUS on a multi-source replica (one row per channel). If the
+// query returns zero rows, the returned list is nil.
+func RowsToMaps(ctx context.Context, db *sql.DB, query string) ([]map[string]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Get list of columns returned by query
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	// Scan() takes pointers, so scanArgs is a list of pointers to values
+	scanArgs := make([]interface{}, len(columns))
+	values := make([]sql.RawBytes, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	var maps []map[string]string
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return nil, err
+		}
+		// Map column => value. RawBytes are only valid until the next
+		// call to Next, so copy them to strings now.
+		m := make(map[string]string, len(columns))
+		for i, col := range columns {
+			m[col] = string(values[i])
+		}
+		maps = append(maps, m)
+	}
+
+	return maps, rows.Err()
+}
+
 // RowToTypedMap converts a single row from query (or the last row) to a map of
 // type T values keyed on column name. All row values are converted to type T.
 // This is used for one-row command outputs which return values of the same type.
@@ -37,3 +37,25 @@ func TestInList(t *testing.T) {
 		t.Errorf("INList(%v, \"'\"): got %s, expected %s", values, result, expected)
 	}
 }
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s      string
+		n      int
+		expect string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"truncated", 5, "trunc"},
+		{"SELECT * FROM café", 18, "SELECT * FROM caf"}, // cut in middle of é (2 bytes)
+		{"SELECT * FROM café", 19, "SELECT * FROM café"},
+		{"日本", 2, ""}, // 3-byte runes
+		{"日本", 4, "日"},
+	}
+	for _, tt := range tests {
+		got := Truncate(tt.s, tt.n)
+		if got != tt.expect {
+			t.Errorf("Truncate(%q, %d) = %q, expected %q", tt.s, tt.n, got, tt.expect)
+		}
+	}
+}
@@ -5,6 +5,7 @@ package mock
 import (
 	"context"
 
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 
 	"github.com/cashapp/blip"
@@ -30,3 +31,14 @@ func (f RDSClientFactory) Make(ba blip.AWS) (blipAWS.RDSClient, error) {
 	}
 	return RDSClient{}, nil
 }
+
+type CloudWatchClient struct {
+	GetMetricDataFunc func(context.Context, *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error)
+}
+
+func (c CloudWatchClient) GetMetricData(ctx context.Context, in *cloudwatch.GetMetricDataInput, _ ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error) {
+	if c.GetMetricDataFunc != nil {
+		return c.GetMetricDataFunc(ctx, in)
+	}
+	return &cloudwatch.GetMetricDataOutput{}, nil
+}